@@ -1,6 +1,7 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,9 +9,37 @@ import (
 	"strings"
 )
 
-func CreateGitIgnore(path string) error {
+// CreateGitIgnore writes a .gitignore to the directory path that ignores all
+// of its files except keep.
+func CreateGitIgnore(path string, keep ...string) error {
 	gitIgnorePath := filepath.Join(path, ".gitignore")
-	return os.WriteFile(gitIgnorePath, []byte("*"), 0o600)
+	lines := []string{"*"}
+	for _, name := range keep {
+		lines = append(lines, "!"+name)
+	}
+	return os.WriteFile(gitIgnorePath, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+}
+
+// KeepInGitIgnore adds an exception for the file name to the .gitignore of the
+// directory path, if it has one, so that the file is checked in.
+func KeepInGitIgnore(path, name string) error {
+	gitIgnorePath := filepath.Join(path, ".gitignore")
+	data, err := os.ReadFile(gitIgnorePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	exception := "!" + name
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == exception {
+			return nil
+		}
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	return os.WriteFile(gitIgnorePath, append(data, exception+"\n"...), 0o600)
 }
 
 func GitRepoURL(wd string) (string, error) {
@@ -0,0 +1,121 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package k8s renders Kubernetes manifests for environment variables.
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type Metadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type Secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   Metadata          `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+type ConfigMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   Metadata          `yaml:"metadata"`
+	Data       map[string]string `yaml:"data,omitempty"`
+}
+
+type Kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Namespace  string   `yaml:"namespace,omitempty"`
+	Resources  []string `yaml:"resources"`
+}
+
+// NewSecret returns an Opaque secret. Values are base64 encoded into data,
+// unless stringData is set, in which case they are stored verbatim.
+func NewSecret(meta Metadata, values map[string]string, stringData bool) *Secret {
+	secret := &Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   meta,
+		Type:       "Opaque",
+	}
+	if stringData {
+		secret.StringData = values
+		return secret
+	}
+	secret.Data = map[string]string{}
+	for k, v := range values {
+		secret.Data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return secret
+}
+
+func NewConfigMap(meta Metadata, values map[string]string) *ConfigMap {
+	return &ConfigMap{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   meta,
+		Data:       values,
+	}
+}
+
+func NewKustomization(namespace string, resources []string) *Kustomization {
+	return &Kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Namespace:  namespace,
+		Resources:  resources,
+	}
+}
+
+// Marshal renders the manifests as a multi-document YAML stream.
+func Marshal(manifests ...any) ([]byte, error) {
+	b := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(b)
+	encoder.SetIndent(2)
+	for _, m := range manifests {
+		if err := encoder.Encode(m); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return b.Bytes(), nil
+}
+
+// ParseSecret returns the values of a Secret manifest, decoding data and
+// merging stringData over it, as the API server does.
+func ParseSecret(manifest []byte) (map[string]string, error) {
+	secret := &Secret{}
+	if err := yaml.Unmarshal(manifest, secret); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if secret.Kind != "Secret" {
+		return nil, errors.Errorf("expected a manifest of kind Secret, but got %q", secret.Kind)
+	}
+	values := map[string]string{}
+	for k, v := range secret.Data {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "data.%s is not valid base64", k)
+		}
+		values[k] = string(decoded)
+	}
+	for k, v := range secret.StringData {
+		values[k] = v
+	}
+	return values, nil
+}
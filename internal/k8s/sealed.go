@@ -0,0 +1,174 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package k8s
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+// Scope controls which secrets a sealed value can be unsealed into. It matches
+// the scopes supported by the sealed-secrets controller.
+type Scope string
+
+const (
+	ScopeStrict        Scope = "strict"
+	ScopeNamespaceWide Scope = "namespace-wide"
+	ScopeClusterWide   Scope = "cluster-wide"
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case ScopeStrict, ScopeNamespaceWide, ScopeClusterWide:
+		return scope, nil
+	case "":
+		return ScopeStrict, nil
+	}
+	return "", errors.Errorf(
+		"invalid sealed secrets scope %q. Must be one of strict|namespace-wide|cluster-wide", s)
+}
+
+type SealedSecret struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Metadata   Metadata         `yaml:"metadata"`
+	Spec       SealedSecretSpec `yaml:"spec"`
+}
+
+type SealedSecretSpec struct {
+	EncryptedData map[string]string `yaml:"encryptedData"`
+	Template      SecretTemplate    `yaml:"template"`
+}
+
+type SecretTemplate struct {
+	Metadata Metadata `yaml:"metadata"`
+	Type     string   `yaml:"type"`
+}
+
+// NewSealedSecret encrypts values with the public key of a sealed-secrets
+// controller so that the resulting manifest can be committed safely.
+func NewSealedSecret(
+	meta Metadata,
+	values map[string]string,
+	pubKey *rsa.PublicKey,
+	scope Scope,
+) (*SealedSecret, error) {
+	meta.Annotations = copyMap(meta.Annotations)
+	var label []byte
+	switch scope {
+	case ScopeStrict:
+		label = []byte(meta.Namespace + "/" + meta.Name)
+	case ScopeNamespaceWide:
+		label = []byte(meta.Namespace)
+		meta.Annotations["sealedsecrets.bitnami.com/namespace-wide"] = "true"
+	case ScopeClusterWide:
+		meta.Annotations["sealedsecrets.bitnami.com/cluster-wide"] = "true"
+	}
+	if scope != ScopeClusterWide && meta.Namespace == "" {
+		return nil, errors.Errorf("a namespace is required for %s sealed secrets", scope)
+	}
+
+	encrypted := map[string]string{}
+	for k, v := range values {
+		ciphertext, err := hybridEncrypt(pubKey, []byte(v), label)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return &SealedSecret{
+		APIVersion: "bitnami.com/v1alpha1",
+		Kind:       "SealedSecret",
+		Metadata:   meta,
+		Spec: SealedSecretSpec{
+			EncryptedData: encrypted,
+			Template: SecretTemplate{
+				Metadata: meta,
+				Type:     "Opaque",
+			},
+		},
+	}, nil
+}
+
+// ParsePublicKey parses a PEM encoded certificate or RSA public key, as
+// printed by `kubeseal --fetch-cert`.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("sealed secrets certificate is not PEM encoded")
+	}
+	var key any
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		key = cert.PublicKey
+	case "PUBLIC KEY":
+		var err error
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	case "RSA PUBLIC KEY":
+		var err error
+		if key, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		return nil, errors.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("sealed secrets certificate must contain an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// hybridEncrypt encrypts plaintext with a one-time AES-256-GCM session key,
+// which is itself encrypted with RSA-OAEP. The output layout is the same as
+// the one used by kubeseal: a 2 byte big endian length of the RSA ciphertext,
+// the RSA ciphertext, and the AES ciphertext.
+func hybridEncrypt(pubKey *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubKey, sessionKey, label)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ciphertext := binary.BigEndian.AppendUint16(nil, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+	// The session key is never reused, so a zero nonce is safe.
+	zeroNonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ciphertext, zeroNonce, plaintext, nil), nil
+}
+
+func copyMap(m map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
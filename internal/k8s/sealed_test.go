@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func TestHybridEncrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	label := []byte("prod/api-secrets")
+	ciphertext, err := hybridEncrypt(&key.PublicKey, []byte("hunter2"), label)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaLen := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(
+		sha256.New(), rand.Reader, key, ciphertext[2:2+rsaLen], label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := aead.Open(
+		nil, make([]byte, aead.NonceSize()), ciphertext[2+rsaLen:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "hunter2" {
+		t.Errorf("Expected %s, but got %s", "hunter2", plaintext)
+	}
+}
+
+func TestSecretManifest(t *testing.T) {
+	secret := NewSecret(
+		Metadata{Name: "api", Namespace: "prod"},
+		map[string]string{"TOKEN": "abc"},
+		false,
+	)
+	data, err := Marshal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: api\n  namespace: prod\n" +
+		"type: Opaque\ndata:\n  TOKEN: YWJj\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, but got %q", expected, string(data))
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package proc runs child processes on behalf of envsec, forwarding signals
+// to them and reporting their exact exit status.
+package proc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+)
+
+// forwardedSignals are relayed to the child instead of terminating envsec.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// Run starts cmd and waits for it to exit. While it runs, interrupt and
+// termination signals received by envsec are forwarded to the child (or to
+// its process group), so that the child decides how to shut down.
+func Run(cmd *exec.Cmd) error {
+	// When stdin is a terminal the child stays in our process group so that it
+	// can read from the terminal. Interrupts from Ctrl+C then already reach the
+	// child, and forwarding them would deliver them twice. The source of a
+	// signal isn't known, so interrupts sent to envsec alone aren't forwarded
+	// in that case either.
+	ownGroup := !isTerminal(cmd.Stdin)
+	configure(cmd, ownGroup)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	for {
+		select {
+		case sig := <-signals:
+			if forwardsSignal(sig, ownGroup) {
+				_ = forward(cmd.Process, sig, ownGroup)
+			}
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// forwardsSignal reports whether sig, received by envsec, is forwarded to a
+// child that has its own process group or not. Children in the process group
+// of the terminal already receive the interrupts from Ctrl+C.
+func forwardsSignal(sig os.Signal, ownGroup bool) bool {
+	return ownGroup || sig != os.Interrupt
+}
+
+// ExitCode returns the exit code that envsec should exit with when err was
+// returned from running a child process. Children terminated by a signal
+// result in 128 + the signal number, like in most shells.
+func ExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+	if code, ok := signalExitCode(exitErr); ok {
+		return code, true
+	}
+	return exitErr.ExitCode(), true
+}
+
+// DedupEnv returns env, a list of NAME=VALUE entries, with a single entry per
+// name. The last value of each name wins, like when os/exec runs a command,
+// but unlike getenv in C programs, which returns the first one.
+func DedupEnv(env []string) []string {
+	index := map[string]int{}
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[name]; ok {
+			result[i] = kv
+			continue
+		}
+		index[name] = len(result)
+		result = append(result, kv)
+	}
+	return result
+}
+
+func isTerminal(r any) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+func init() {
+	forwardedSignals = append(forwardedSignals, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+func configure(cmd *exec.Cmd, ownGroup bool) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = ownGroup
+}
+
+func forward(p *os.Process, sig os.Signal, ownGroup bool) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	if ownGroup {
+		// A negative pid signals the whole process group.
+		return syscall.Kill(-p.Pid, s)
+	}
+	return p.Signal(s)
+}
+
+func signalExitCode(exitErr *exec.ExitError) (int, bool) {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+	return 128 + int(status.Signal()), true
+}
+
+// Exec replaces the current process with the program at argv[0], so that
+// envsec does not stay resident. It only returns on error. Names that appear
+// more than once in env get their last value, as they do with Run.
+func Exec(argv, env []string) error {
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, DedupEnv(env))
+}
+
+func killProcess(p *os.Process, ownGroup bool) error {
+	return forward(p, syscall.SIGKILL, ownGroup)
+}
+
+// ParseSignal returns the signal with the given name, such as SIGTERM or HUP.
+func ParseSignal(name string) (os.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if sig, ok := signalsByName[name]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported signal %q", name)
+}
+
+var signalsByName = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
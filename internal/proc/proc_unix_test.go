@@ -0,0 +1,131 @@
+//go:build !windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected int
+	}{
+		{"exit 3", 3},
+		{"kill -TERM $$", 128 + 15},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			err := Run(exec.Command("/bin/sh", "-c", test.script))
+			code, ok := ExitCode(err)
+			if !ok {
+				t.Fatalf("Expected an exit error, but got %v", err)
+			}
+			if code != test.expected {
+				t.Errorf("Expected %d, but got %d", test.expected, code)
+			}
+		})
+	}
+}
+
+// TestExecLastValueWins runs Exec, as envsec exec --replace does, in a copy of
+// the test binary, because it replaces the process.
+func TestExecLastValueWins(t *testing.T) {
+	if _, err := exec.LookPath("printenv"); err != nil {
+		t.Skip("printenv is not installed")
+	}
+	if os.Getenv("PROC_TEST_EXEC") == "1" {
+		// printenv uses getenv, which returns the first entry of a name.
+		err := Exec(
+			[]string{"printenv", "SHARED"},
+			[]string{"SHARED=local", "PATH=" + os.Getenv("PATH"), "SHARED=remote"},
+		)
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExecLastValueWins$")
+	cmd.Env = append(os.Environ(), "PROC_TEST_EXEC=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "remote\n" {
+		t.Errorf("Expected the last value to win, but got %q", out)
+	}
+}
+
+func TestRunPreservesArgs(t *testing.T) {
+	out := strings.Builder{}
+	cmd := exec.Command("/bin/sh", "-c", `printf '%s|' "$@"`, "sh", "a b", `"c"`)
+	cmd.Stdout = &out
+	if err := Run(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != `a b|"c"|` {
+		t.Errorf("Unexpected output %s", out.String())
+	}
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	runs := 0
+	var supervisor *Supervisor
+	supervisor = NewSupervisor(func() (*exec.Cmd, error) {
+		runs++
+		if runs == 1 {
+			// Ignore the stop signal so that the command has to be killed.
+			supervisor.Restart()
+			return exec.Command("/bin/sh", "-c", "trap '' TERM; sleep 5"), nil
+		}
+		return exec.Command("/bin/sh", "-c", "exit 4"), nil
+	}, syscall.SIGTERM, 100*time.Millisecond)
+
+	code, _ := ExitCode(supervisor.Run())
+	if runs != 2 || code != 4 {
+		t.Errorf("Expected 2 runs exiting with 4, but got %d runs exiting with %d", runs, code)
+	}
+}
+
+func TestForwardsSignal(t *testing.T) {
+	tests := []struct {
+		sig      os.Signal
+		ownGroup bool
+		expected bool
+	}{
+		// Ctrl+C already reaches children in the terminal's process group.
+		{os.Interrupt, false, false},
+		{os.Interrupt, true, true},
+		{syscall.SIGTERM, false, true},
+		{syscall.SIGTERM, true, true},
+	}
+	for _, test := range tests {
+		if got := forwardsSignal(test.sig, test.ownGroup); got != test.expected {
+			t.Errorf("forwardsSignal(%v, %t) = %t, expected %t",
+				test.sig, test.ownGroup, got, test.expected)
+		}
+	}
+}
+
+func TestSupervisorForwardsSignals(t *testing.T) {
+	started := make(chan struct{})
+	supervisor := NewSupervisor(func() (*exec.Cmd, error) {
+		close(started)
+		return exec.Command("/bin/sh", "-c", "sleep 5"), nil
+	}, syscall.SIGTERM, time.Second)
+
+	go func() {
+		<-started
+		time.Sleep(100 * time.Millisecond)
+		// envsec receives the interrupt, and the command isn't in the
+		// terminal's process group, so it's forwarded.
+		_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+	code, _ := ExitCode(supervisor.Run())
+	if code != 128+int(syscall.SIGINT) {
+		t.Errorf("Expected the command to be interrupted, but it exited with %d", code)
+	}
+}
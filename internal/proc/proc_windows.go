@@ -0,0 +1,43 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build windows
+
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func configure(cmd *exec.Cmd, ownGroup bool) {}
+
+func forward(p *os.Process, sig os.Signal, ownGroup bool) error {
+	// Windows can't deliver interrupts to other processes. The console already
+	// sends Ctrl+C to every process attached to it, including the child.
+	return nil
+}
+
+func signalExitCode(exitErr *exec.ExitError) (int, bool) {
+	return 0, false
+}
+
+func Exec(argv, env []string) error {
+	return errors.New("replacing the envsec process is not supported on Windows")
+}
+
+func killProcess(p *os.Process, ownGroup bool) error {
+	return p.Kill()
+}
+
+// ParseSignal returns the signal with the given name. On Windows the only
+// supported signal is KILL.
+func ParseSignal(name string) (os.Signal, error) {
+	if strings.TrimPrefix(strings.ToUpper(name), "SIG") == "KILL" {
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("unsupported signal %q", name)
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// Supervisor runs a command and restarts it on demand, for example when the
+// variables it was started with change.
+type Supervisor struct {
+	// NewCmd returns the command to run. It is called again for every restart,
+	// so that the new process can pick up a new environment.
+	NewCmd func() (*exec.Cmd, error)
+	// StopSignal is sent to the command to stop it before a restart.
+	StopSignal os.Signal
+	// GracePeriod is how long to wait for the command to exit after
+	// StopSignal, before it is killed.
+	GracePeriod time.Duration
+
+	restart chan struct{}
+	signals chan os.Signal
+}
+
+func NewSupervisor(
+	newCmd func() (*exec.Cmd, error),
+	stopSignal os.Signal,
+	gracePeriod time.Duration,
+) *Supervisor {
+	return &Supervisor{
+		NewCmd:      newCmd,
+		StopSignal:  stopSignal,
+		GracePeriod: gracePeriod,
+		restart:     make(chan struct{}, 1),
+		signals:     make(chan os.Signal, 1),
+	}
+}
+
+// Restart gracefully stops the running command and starts it again.
+func (s *Supervisor) Restart() {
+	select {
+	case s.restart <- struct{}{}:
+	default:
+		// A restart is already pending.
+	}
+}
+
+// Signal sends sig to the running command. Signals sent while a previous one
+// hasn't been delivered yet are dropped.
+func (s *Supervisor) Signal(sig os.Signal) {
+	select {
+	case s.signals <- sig:
+	default:
+	}
+}
+
+// Run starts the command and blocks until it exits by itself, returning its
+// error. Exits caused by Restart are not reported; the command is started
+// again instead. Like Run, signals received by envsec are forwarded, except
+// for interrupts that already reached a command in the terminal's process
+// group.
+func (s *Supervisor) Run() error {
+	osSignals := make(chan os.Signal, 1)
+	signal.Notify(osSignals, forwardedSignals...)
+	defer signal.Stop(osSignals)
+
+	for {
+		cmd, err := s.NewCmd()
+		if err != nil {
+			return err
+		}
+		ownGroup := !isTerminal(cmd.Stdin)
+		configure(cmd, ownGroup)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		restarting := false
+		var kill <-chan time.Time
+	wait:
+		for {
+			select {
+			case sig := <-osSignals:
+				if forwardsSignal(sig, ownGroup) {
+					_ = forward(cmd.Process, sig, ownGroup)
+				}
+			case sig := <-s.signals:
+				_ = forward(cmd.Process, sig, ownGroup)
+			case <-s.restart:
+				if !restarting {
+					restarting = true
+					_ = forward(cmd.Process, s.StopSignal, ownGroup)
+					kill = time.After(s.GracePeriod)
+				}
+			case <-kill:
+				_ = killProcess(cmd.Process, ownGroup)
+			case err := <-done:
+				if !restarting {
+					return err
+				}
+				break wait
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package redact removes secret values from streams of output.
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted value.
+const Mask = "*****"
+
+// Variants returns the strings to redact for a secret value: the value itself,
+// each line of multi-line values, and their base64 and URL encoded forms.
+// Strings shorter than minLength are skipped to avoid false positives. The
+// result is sorted by length, longest first, so that a variant that contains
+// another one is matched as a whole.
+func Variants(value string, minLength int) []string {
+	plain := []string{value}
+	if strings.ContainsAny(value, "\r\n") {
+		for _, line := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == '\r' || r == '\n'
+		}) {
+			plain = append(plain, strings.TrimSpace(line))
+		}
+	}
+
+	seen := map[string]bool{}
+	result := []string{}
+	add := func(s string) {
+		if len(s) >= minLength && len(s) > 0 && !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	for _, p := range plain {
+		add(p)
+		if len(p) < minLength {
+			continue
+		}
+		add(base64.StdEncoding.EncodeToString([]byte(p)))
+		add(base64.RawStdEncoding.EncodeToString([]byte(p)))
+		add(base64.URLEncoding.EncodeToString([]byte(p)))
+		add(base64.RawURLEncoding.EncodeToString([]byte(p)))
+		add(url.QueryEscape(p))
+		add(url.PathEscape(p))
+	}
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// Writer replaces secrets in everything written to it before passing it on.
+// Secrets that are split across several writes are still redacted: output
+// that could be the beginning of a secret is held back until it can be
+// decided, or until Close is called.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	byFirst map[byte][][]byte
+	pending []byte
+}
+
+func NewWriter(w io.Writer, secrets []string) *Writer {
+	byFirst := map[byte][][]byte{}
+	// Longer secrets are matched first, so that a secret that contains another
+	// one is redacted as a whole.
+	sorted := append([]string{}, secrets...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, s := range sorted {
+		if s == "" {
+			continue
+		}
+		byFirst[s[0]] = append(byFirst[s[0]], []byte(s))
+	}
+	return &Writer{w: w, byFirst: byFirst}
+}
+
+func (r *Writer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, p...)
+	if err := r.flush(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes out any output that was held back. It does not close the
+// underlying writer.
+func (r *Writer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush(true)
+}
+
+// flush writes out as much of the pending output as possible. Unless final is
+// set, it stops at the first position that is a prefix of a secret. Secrets
+// are tried longest first, and a shorter secret only matches once no longer
+// one can, so that a secret that contains another one is redacted as a whole
+// even when it is split across writes.
+func (r *Writer) flush(final bool) error {
+	out := bytes.Buffer{}
+	i := 0
+scan:
+	for i < len(r.pending) {
+		rest := r.pending[i:]
+		for _, s := range r.byFirst[rest[0]] {
+			if bytes.HasPrefix(rest, s) {
+				out.WriteString(Mask)
+				i += len(s)
+				continue scan
+			}
+			if !final && len(rest) < len(s) && bytes.HasPrefix(s, rest) {
+				break scan
+			}
+		}
+		out.WriteByte(rest[0])
+		i++
+	}
+	r.pending = append(r.pending[:0], r.pending[i:]...)
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := r.w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,89 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	secrets := Variants("hunter2", 4)
+	secrets = append(secrets, Variants("line one\nline two", 4)...)
+	secrets = append(secrets, Variants("abc", 4)...)
+
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"single write", []string{"password=hunter2\n"}, "password=*****\n"},
+		{"split write", []string{"password=hun", "ter2\n"}, "password=*****\n"},
+		{"byte by byte", strings.Split("x hunter2 y", ""), "x ***** y"},
+		{"false prefix", []string{"hunt", "ing"}, "hunting"},
+		{"prefix at end", []string{"hunter"}, "hunter"},
+		{"base64", []string{"aHVudGVyMg=="}, "*****"},
+		{"url encoded", []string{"a=line+one%0Aline+two"}, "a=*****"},
+		{"multi-line", []string{"line one\nline two"}, "*****"},
+		{"single line of multi-line", []string{"got line two"}, "got *****"},
+		{"too short", []string{"abc"}, "abc"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := strings.Builder{}
+			w := NewWriter(&out, secrets)
+			for _, s := range test.writes {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("Expected %q, but got %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestWriterOverlappingSecrets(t *testing.T) {
+	// The shorter secret is listed first and is a prefix of the longer one.
+	secrets := append(Variants("sk_live", 4), Variants("sk_live_12345", 4)...)
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"single write", []string{"key=sk_live_12345\n"}, "key=*****\n"},
+		{"split after shorter", []string{"key=sk_live", "_12345\n"}, "key=*****\n"},
+		{"byte by byte", strings.Split("key=sk_live_12345", ""), "key=*****"},
+		{"shorter alone", []string{"key=sk_live", "\n"}, "key=*****\n"},
+		{"shorter at end", []string{"key=sk_live"}, "key=*****"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := strings.Builder{}
+			w := NewWriter(&out, secrets)
+			for _, s := range test.writes {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("Expected %q, but got %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestVariantsLongestFirst(t *testing.T) {
+	variants := Variants("line one\nline two", 4)
+	for i := 1; i < len(variants); i++ {
+		if len(variants[i]) > len(variants[i-1]) {
+			t.Fatalf("Expected variants sorted longest first, but got %q", variants)
+		}
+	}
+}
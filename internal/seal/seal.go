@@ -0,0 +1,143 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package seal encrypts data at rest with age (https://age-encryption.org),
+// either to X25519 recipients or with a passphrase.
+package seal
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+)
+
+// Encrypt encrypts data to all recipients and returns it ASCII armored.
+func Encrypt(data []byte, recipients ...age.Recipient) ([]byte, error) {
+	out := bytes.Buffer{}
+	armored := armor.NewWriter(&out)
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := armored.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt with any of identities.
+func Decrypt(data []byte, identities ...age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identities...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	plain, err := io.ReadAll(r)
+	return plain, errors.WithStack(err)
+}
+
+// Passphrase returns a recipient and an identity for a passphrase.
+func Passphrase(passphrase string) (age.Recipient, age.Identity, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return recipient, identity, nil
+}
+
+// ParseRecipients parses age X25519 recipients, such as age1ql3z7hjy54pw3...
+func ParseRecipients(recipients []string) ([]age.Recipient, error) {
+	result := []age.Recipient{}
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid recipient %q", r)
+		}
+		result = append(result, recipient)
+	}
+	return result, nil
+}
+
+// ReadRecipients reads the recipients in a file with one recipient per line.
+// Empty lines and lines starting with # are ignored.
+func ReadRecipients(path string) ([]age.Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	recipients, err := age.ParseRecipients(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse recipients file %s", path)
+	}
+	return recipients, nil
+}
+
+// ReadIdentities reads the identities in an age identity file, as created by
+// age-keygen or LoadOrCreateIdentity.
+func ReadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse identity file %s", path)
+	}
+	return identities, nil
+}
+
+// LoadOrCreateIdentity reads the X25519 identity at path, generating it with
+// 0600 permissions if it doesn't exist yet.
+func LoadOrCreateIdentity(path string) (*age.X25519Identity, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		identities, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse identity file %s", path)
+		}
+		for _, identity := range identities {
+			if x, ok := identity.(*age.X25519Identity); ok {
+				return x, nil
+			}
+		}
+		return nil, errors.Errorf("identity file %s contains no X25519 identity", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	content := "# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	// O_EXCL avoids overwriting an identity created concurrently.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+	return identity, errors.WithStack(f.Close())
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"filippo.io/age"
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/internal/seal"
+	"go.jetify.com/envsec/pkg/envsec"
+	"go.jetify.com/pkg/envvar"
+)
+
+// passphraseFlag is composed into the flags of commands that encrypt or
+// decrypt bundles and snapshots.
+type passphraseFlag struct {
+	passphrase bool
+}
+
+func (f *passphraseFlag) registerPassphrase(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&f.passphrase,
+		"passphrase",
+		false,
+		"use a passphrase instead of age keys. It is read from $ENVSEC_PASSPHRASE, "+
+			"or prompted for",
+	)
+}
+
+// readPassphrase returns the passphrase from $ENVSEC_PASSPHRASE, or prompts
+// for it, twice if confirm is set.
+func (f *passphraseFlag) readPassphrase(cmd *cobra.Command, confirm bool) (string, error) {
+	passphrase := envvar.Get("ENVSEC_PASSPHRASE", "")
+	if passphrase == "" {
+		var err error
+		passphrase, err = promptSecret(cmd, "Passphrase", confirm)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read passphrase. Set $ENVSEC_PASSPHRASE instead")
+		}
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase can not be empty")
+	}
+	return passphrase, nil
+}
+
+// recipientFlags is composed into the flags of commands that encrypt to age
+// recipients or a passphrase.
+type recipientFlags struct {
+	passphraseFlag
+	recipients     []string
+	recipientsFile string
+}
+
+func (f *recipientFlags) registerRecipients(cmd *cobra.Command, what string) {
+	cmd.Flags().StringArrayVarP(
+		&f.recipients,
+		"recipient",
+		"r",
+		nil,
+		"age public key (age1...) to encrypt the "+what+" to. Can be repeated",
+	)
+	cmd.Flags().StringVar(
+		&f.recipientsFile,
+		"recipients-file",
+		"",
+		"file with one age public key per line to encrypt the "+what+" to",
+	)
+	f.registerPassphrase(cmd)
+}
+
+func (f *recipientFlags) parseRecipients(cmd *cobra.Command) ([]age.Recipient, error) {
+	if f.passphrase {
+		if len(f.recipients) > 0 || f.recipientsFile != "" {
+			return nil, errors.New("--passphrase can not be combined with recipients")
+		}
+		passphrase, err := f.readPassphrase(cmd, true /*confirm*/)
+		if err != nil {
+			return nil, err
+		}
+		recipient, _, err := seal.Passphrase(passphrase)
+		return []age.Recipient{recipient}, err
+	}
+	recipients, err := seal.ParseRecipients(f.recipients)
+	if err != nil {
+		return nil, err
+	}
+	if f.recipientsFile != "" {
+		fromFile, err := seal.ReadRecipients(f.recipientsFile)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, fromFile...)
+	}
+	return recipients, nil
+}
+
+// identityFlags is composed into the flags of commands that decrypt with age
+// identities or a passphrase.
+type identityFlags struct {
+	passphraseFlag
+	identities []string
+}
+
+func (f *identityFlags) registerIdentities(cmd *cobra.Command, what string) {
+	cmd.Flags().StringArrayVarP(
+		&f.identities,
+		"identity",
+		"i",
+		nil,
+		"age identity file to decrypt the "+what+" with. Can be repeated. "+
+			"Defaults to your envsec identity",
+	)
+	f.registerPassphrase(cmd)
+}
+
+func (f *identityFlags) parseIdentities(cmd *cobra.Command) ([]age.Identity, error) {
+	result := []age.Identity{}
+	if f.passphrase {
+		passphrase, err := f.readPassphrase(cmd, false /*confirm*/)
+		if err != nil {
+			return nil, err
+		}
+		_, identity, err := seal.Passphrase(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, identity)
+	}
+	for _, path := range f.identities {
+		identities, err := seal.ReadIdentities(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, identities...)
+	}
+	return result, nil
+}
+
+type backupCmdFlags struct {
+	configFlags
+	recipientFlags
+	output string
+}
+
+func backupCmd() *cobra.Command {
+	flags := &backupCmdFlags{}
+	command := &cobra.Command{
+		Use:   "backup -o <file>",
+		Short: "Export every environment of the project to an encrypted bundle",
+		Long: heredoc.Doc(`
+			Export the variables and configuration of every environment of the
+			project to a single encrypted bundle, which can be restored with
+			envsec restore.
+
+			The bundle is encrypted with age (https://age-encryption.org) to the
+			given recipients or to a passphrase, one of which is required so that
+			the bundle can be restored if this machine is lost.
+
+			Bundle format: the first line is "envsec-bundle v<version>", currently
+			v1. The rest is an ASCII armored age file that decrypts to JSON:
+
+			  {
+			    "version": 1,
+			    "created_at": "<RFC 3339 time>",
+			    "project_id": "<project>",
+			    "org_id": "<organization>",
+			    "environments": {
+			      "<name>": {
+			        "config": {"extends": "<name>", "protected": false, "frozen": false},
+			        "variables": {"<NAME>": "<value>"},
+			        "encodings": {"<NAME>": "base64"}
+			      }
+			    }
+			  }
+
+			Binary values are base64 encoded and listed in encodings.
+
+			It can be decrypted with the age CLI, e.g.
+			tail -n +2 project.bundle | age -d -i key.txt
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if !flags.passphrase && len(flags.recipients) == 0 && flags.recipientsFile == "" {
+				return errors.New("pass --recipient, --recipients-file or --passphrase to encrypt the backup")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			recipients, err := flags.parseRecipients(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.Backup(cmd.Context(), flags.output, recipients...)
+		},
+	}
+	command.Flags().StringVarP(
+		&flags.output,
+		"output",
+		"o",
+		"",
+		"path of the bundle to write",
+	)
+	_ = command.MarkFlagRequired("output")
+	flags.registerRecipients(command, "bundle")
+	flags.register(command)
+	return command
+}
+
+type restoreCmdFlags struct {
+	configFlags
+	identityFlags
+	transactionFlag
+	dryRun bool
+	prune  bool
+}
+
+func restoreCmd() *cobra.Command {
+	flags := &restoreCmdFlags{}
+	command := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore environments from a bundle created by envsec backup",
+		Long: "Restore environments from a bundle created by envsec backup, into " +
+			"the current project and store. The changes to each environment are " +
+			"shown and must be confirmed before they are made.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			identities, err := flags.parseIdentities(cmd)
+			if err != nil {
+				return err
+			}
+			opts := envsec.RestoreBundleOptions{
+				Identities: identities,
+				DryRun:     flags.dryRun,
+				Prune:      flags.prune,
+			}
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg.envsec.Transactional = flags.transactional
+			return cmdCfg.envsec.RestoreBundle(cmd.Context(), args[0], opts)
+		},
+	}
+	flags.registerIdentities(command, "bundle")
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"only show the changes that restoring would make",
+	)
+	command.Flags().BoolVar(
+		&flags.prune,
+		"prune",
+		false,
+		"delete variables that are not in the bundle",
+	)
+	flags.registerTransaction(command)
+	flags.register(command)
+	return command
+}
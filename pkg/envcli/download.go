@@ -4,6 +4,10 @@
 package envcli
 
 import (
+	"os"
+	"strconv"
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/pkg/envsec"
@@ -11,7 +15,11 @@ import (
 
 type downloadCmdFlags struct {
 	configFlags
-	format string
+	localFileFlag
+	binaryFlag
+	format   string
+	merge    bool
+	fileMode string
 }
 
 func DownloadCmd() *cobra.Command {
@@ -19,9 +27,15 @@ func DownloadCmd() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "download <file1>",
 		Short: "Download environment variables into the specified file",
-		Long:  "Download environment variables stored into the specified file (most commonly a .env file). The format of the file is one NAME=VALUE per line.",
+		Long:  "Download environment variables stored into the specified file (most commonly a .env file). By default the format of the file is one NAME=VALUE per line. Use - as the file to write to stdout. Binary values are base64 encoded unless --binary is raw.",
 		Args:  cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := parseFileMode(flags.fileMode); err != nil {
+				return err
+			}
+			if _, err := flags.binaryEncoding(); err != nil {
+				return err
+			}
 			return envsec.ValidateFormat(flags.format)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -29,13 +43,61 @@ func DownloadCmd() *cobra.Command {
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			return cmdCfg.envsec.Download(cmd.Context(), args[0], flags.format)
+			cmdCfg.envsec.LocalFile = flags.localFile
+			// Merging keeps the permissions of the existing file, up to
+			// 0600, unless they are set explicitly.
+			var fileMode os.FileMode
+			if !flags.merge || cmd.Flags().Changed("file-mode") {
+				if fileMode, err = parseFileMode(flags.fileMode); err != nil {
+					return err
+				}
+			}
+			binary, err := flags.binaryEncoding()
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.Download(cmd.Context(), args[0], envsec.DownloadOptions{
+				Format:   flags.format,
+				Merge:    flags.merge,
+				FileMode: fileMode,
+				Binary:   binary,
+			})
 		},
 	}
 
+	flags.registerLocalFile(command)
+	flags.registerBinary(command)
 	flags.register(command)
 	command.Flags().StringVarP(
-		&flags.format, "format", "f", "env", "file format: dotenv or json")
+		&flags.format,
+		"format",
+		"f",
+		"",
+		"file format, one of: "+strings.Join(envsec.FormatNames(), ", ")+
+			". Inferred from the file extension if not set",
+	)
+	command.Flags().BoolVar(
+		&flags.merge,
+		"merge",
+		false,
+		"update only the stored variables in an existing file, preserving "+
+			"comments, ordering and local-only variables",
+	)
+	command.Flags().StringVar(
+		&flags.fileMode,
+		"file-mode",
+		"0600",
+		"permissions of the downloaded file, in octal. With --merge, an existing file keeps its "+
+			"permissions, but never looser than 0600, unless this is set",
+	)
 
 	return command
 }
+
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, errors.Errorf("invalid file mode %q. Must be an octal value such as 0600", s)
+	}
+	return os.FileMode(mode), nil
+}
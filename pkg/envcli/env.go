@@ -0,0 +1,210 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/internal/tux"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+func envCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "env",
+		Short: "Manage the environments of the project",
+		Long: "Manage the environments of the project. Environments are listed in " +
+			".jetify/environments.json, which is checked in so that teammates and CI " +
+			"share them, and default to dev, preview and prod. Commands warn about " +
+			"environments that aren't listed, but still use them. Names are case " +
+			"sensitive. The Jetify store only supports dev, preview and prod, so " +
+			"other environments require a store without that limit.",
+	}
+	command.AddCommand(envListCmd())
+	command.AddCommand(envCreateCmd())
+	command.AddCommand(envRemoveCmd())
+	command.AddCommand(envRenameCmd())
+	command.AddCommand(envSettingCmd("protect", "Require confirmation to change an environment",
+		func(e *envsec.Envsec, name string) error { return e.ProtectEnvironment(name, true) }))
+	command.AddCommand(envSettingCmd("unprotect", "Stop requiring confirmation to change an environment",
+		func(e *envsec.Envsec, name string) error { return e.ProtectEnvironment(name, false) }))
+	command.AddCommand(envSettingCmd("freeze", "Make an environment read-only",
+		func(e *envsec.Envsec, name string) error { return e.FreezeEnvironment(name, true) }))
+	command.AddCommand(envSettingCmd("unfreeze", "Make a frozen environment writable again",
+		func(e *envsec.Envsec, name string) error { return e.FreezeEnvironment(name, false) }))
+	return command
+}
+
+func envListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List the environments of the project",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := projectEnvsec(cmd)
+			if err != nil {
+				return err
+			}
+			environments, err := e.Environments()
+			if err != nil {
+				return err
+			}
+			names := lo.Keys(environments)
+			sort.Strings(names)
+			rows := [][]string{}
+			for _, name := range names {
+				cfg := environments[name]
+				settings := []string{}
+				if cfg.Extends != "" {
+					settings = append(settings, "extends "+cfg.Extends)
+				}
+				if cfg.Protected {
+					settings = append(settings, "protected")
+				}
+				if cfg.Frozen {
+					settings = append(settings, "frozen")
+				}
+				rows = append(rows, []string{name, strings.Join(settings, ", ")})
+			}
+			return tux.FTable(cmd.OutOrStdout(), rows)
+		},
+	}
+}
+
+type envCreateCmdFlags struct {
+	extends string
+}
+
+func envCreateCmd() *cobra.Command {
+	flags := &envCreateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Add an environment to the project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := projectEnvsec(cmd)
+			if err != nil {
+				return err
+			}
+			return e.CreateEnvironment(args[0], envsec.EnvironmentConfig{
+				Extends: flags.extends,
+			})
+		},
+	}
+	command.Flags().StringVar(
+		&flags.extends,
+		"extends",
+		"",
+		"environment that the new environment inherits variables from",
+	)
+	return command
+}
+
+type envRemoveCmdFlags struct {
+	configFlags
+	force bool
+}
+
+func envRemoveCmd() *cobra.Command {
+	flags := &envRemoveCmdFlags{}
+	command := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Remove an environment from the project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.RemoveEnvironment(cmd.Context(), args[0], flags.force)
+		},
+	}
+	command.Flags().BoolVarP(
+		&flags.force,
+		"force",
+		"f",
+		false,
+		"delete the variables of the environment along with it",
+	)
+	flags.register(command)
+	return command
+}
+
+type envRenameCmdFlags struct {
+	configFlags
+}
+
+func envRenameCmd() *cobra.Command {
+	flags := &envRenameCmdFlags{}
+	command := &cobra.Command{
+		Use:   "rename <old-name> <new-name>",
+		Short: "Rename an environment, moving its variables",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.RenameEnvironment(cmd.Context(), args[0], args[1])
+		},
+	}
+	flags.register(command)
+	return command
+}
+
+type envSettingCmdFlags struct {
+	yes bool
+}
+
+// envSettingCmd returns a command that changes a setting of an environment.
+func envSettingCmd(
+	use, short string,
+	apply func(e *envsec.Envsec, name string) error,
+) *cobra.Command {
+	flags := &envSettingCmdFlags{}
+	command := &cobra.Command{
+		Use:   use + " <name>",
+		Short: short,
+		Long: short + ". Protected and frozen environments are recorded in " +
+			".jetify/environments.json, which is checked in, so that teammates and " +
+			"CI share them. They guard against mistakes, but the store doesn't " +
+			"enforce them.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := projectEnvsec(cmd)
+			if err != nil {
+				return err
+			}
+			e.AssumeYes = flags.yes
+			return apply(e, args[0])
+		},
+	}
+	command.Flags().BoolVarP(
+		&flags.yes,
+		"yes",
+		"y",
+		false,
+		"don't ask for confirmation",
+	)
+	return command
+}
+
+// projectEnvsec returns an Envsec for commands that only read or change the
+// project config, which don't require a login. Its store isn't initialized,
+// and is only used to check which environments it supports.
+func projectEnvsec(cmd *cobra.Command) (*envsec.Envsec, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	e := defaultEnvsec(cmd, wd)
+	e.Store = defaultStore()
+	return e, nil
+}
@@ -4,50 +4,437 @@
 package envcli
 
 import (
-	"fmt"
-	"os"
+	"context"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/internal/proc"
+	"go.jetify.com/envsec/internal/redact"
+	"go.jetify.com/envsec/internal/tux"
+	"go.jetify.com/envsec/pkg/envsec"
 )
 
 type execCmdFlags struct {
 	configFlags
+	localFileFlag
+	binaryFlag
+	noShell         bool
+	replace         bool
+	redact          bool
+	redactExclude   []string
+	redactMinLength int
+	watch           bool
+	watchInterval   time.Duration
+	restartSignal   string
+	gracePeriod     time.Duration
+	reload          bool
+	reloadSignal    string
+	files           []string
+	fileManifest    string
+	noInherit       bool
+	inherit         []string
+	only            []string
+	exclude         []string
+	prefix          string
+	precedence      string
 }
 
+const (
+	precedenceRemote = "remote"
+	precedenceLocal  = "local"
+)
+
 func ExecCmd() *cobra.Command {
 	flags := &execCmdFlags{}
 	command := &cobra.Command{
 		Use:   "exec <command>",
 		Short: "Execute a command with Jetify-stored environment variables",
-		Long:  "Execute a specified command with remote environment variables being present for the duration of the command. If an environment variable exists both locally and in remote storage, the remotely stored one is prioritized.",
-		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Execute a specified command with remote environment variables being
+			present for the duration of the command. If an environment variable
+			exists both locally and in remote storage, the remotely stored one is
+			prioritized, unless --precedence is set to local. Local environment
+			variables containing envsec://<project>/<environment>/<NAME>
+			references are resolved before the command runs.
+
+			With --no-inherit, the command only receives the stored variables and
+			the local variables matching --inherit. --only and --exclude select
+			the stored variables by glob pattern, and --prefix is prepended to
+			their names, e.g.:
+
+			  envsec exec --no-inherit --only 'DB_*' --prefix APP_ -- ./server
+
+			By default the arguments are joined with spaces and run with /bin/sh.
+			With --no-shell the command is executed directly and each argument is
+			passed exactly as given, e.g.:
+
+			  envsec exec --no-shell -- cmd arg "with spaces"
+
+			Interrupt, terminate and hangup signals are forwarded to the command,
+			and envsec exits with the exit status of the command.
+
+			With --redact, stored values are replaced with ***** in the output of
+			the command, including their base64 and URL encoded forms.
+
+			With --watch, envsec keeps checking the stored variables and restarts
+			the command when they change. The command is stopped with
+			--restart-signal and killed if it is still running after
+			--grace-period. With --reload, it is sent --reload-signal instead of
+			being restarted.
+
+			With --file NAME[:path], the value of NAME is written to a file in a
+			private temporary directory instead of being set in the environment,
+			and NAME_FILE is set to the path of the file. path is relative to the
+			directory and defaults to NAME. --file-manifest reads the same mapping
+			from a JSON, YAML or TOML file. The files are removed when the command
+			exits.
+
+			Environment variables can only hold text, so binary values, such as
+			those set with NAME=@file.p12, are base64 encoded. With --binary raw
+			they are set as they are, unless they contain NUL bytes. --file
+			always writes the bytes of the value.
+		`),
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.replace && (flags.redact || flags.watch ||
+				len(flags.files) > 0 || flags.fileManifest != "") {
+				return errors.New(
+					"--redact, --watch and --file can't be used with --replace")
+			}
+			if flags.precedence != precedenceRemote && flags.precedence != precedenceLocal {
+				return errors.Errorf(
+					"invalid --precedence %q. Must be one of: %s, %s",
+					flags.precedence,
+					precedenceRemote,
+					precedenceLocal,
+				)
+			}
+			if flags.watch && flags.watchInterval <= 0 {
+				return errors.New("--watch-interval must be positive")
+			}
+			if _, err := proc.ParseSignal(flags.restartSignal); err != nil {
+				return err
+			}
+			if _, err := proc.ParseSignal(flags.reloadSignal); err != nil {
+				return err
+			}
+			_, err := flags.binaryEncoding()
+			return err
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
 				return err
 			}
-			commandString := strings.Join(args, " ")
-			commandToRun := exec.Command("/bin/sh", "-c", commandString)
+			cmdCfg.envsec.LocalFile = flags.localFile
+			binary, err := flags.binaryEncoding()
+			if err != nil {
+				return err
+			}
+
+			argv := args
+			if !flags.noShell {
+				argv = []string{"/bin/sh", "-c", strings.Join(args, " ")}
+			}
 
-			// Get list of stored env variables
 			envVars, err := cmdCfg.envsec.List(cmd.Context())
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			// Attach stored env variables to the command environment
-			commandToRun.Env = os.Environ()
-			for _, envVar := range envVars {
-				commandToRun.Env = append(commandToRun.Env, fmt.Sprintf("%s=%s", envVar.Name, envVar.Value))
+			secretFiles, err := flags.secretFiles()
+			if err != nil {
+				return err
+			}
+			if secretFiles != nil {
+				// Commands that exit on a forwarded signal still return here,
+				// so the files are removed in that case too.
+				defer func() { _ = secretFiles.Remove() }()
 			}
-			commandToRun.Stdin = cmd.InOrStdin()
-			commandToRun.Stdout = cmd.OutOrStdout()
-			commandToRun.Stderr = cmd.ErrOrStderr()
-			return commandToRun.Run()
+			execEnv := func(envVars []envsec.EnvVar) ([]string, error) {
+				envVars = envsec.FilterEnvVars(envVars, flags.only, flags.exclude)
+				if secretFiles != nil {
+					var err error
+					if envVars, err = secretFiles.Write(envVars); err != nil {
+						return nil, err
+					}
+				}
+				return cmdCfg.envsec.ExecEnv(cmd.Context(), envVars, envsec.ExecOptions{
+					NoInherit: flags.noInherit,
+					Inherit:   flags.inherit,
+					Prefix:    flags.prefix,
+					LocalWins: flags.precedence == precedenceLocal,
+					Binary:    binary,
+				})
+			}
+			env, err := execEnv(envVars)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if flags.replace {
+				return proc.Exec(argv, env)
+			}
+
+			// Output redacted with the secrets of the previous run is flushed
+			// before a new run starts, and once the command exits.
+			var redactors []*redact.Writer
+			closeRedactors := func() {
+				for _, r := range redactors {
+					_ = r.Close()
+				}
+				redactors = nil
+			}
+			defer closeRedactors()
+
+			newCmd := func() (*exec.Cmd, error) {
+				closeRedactors()
+				commandToRun := exec.Command(argv[0], argv[1:]...)
+				commandToRun.Env = env
+				commandToRun.Stdin = cmd.InOrStdin()
+				commandToRun.Stdout = cmd.OutOrStdout()
+				commandToRun.Stderr = cmd.ErrOrStderr()
+				if flags.redact {
+					secrets := envsec.SecretsToRedact(
+						envVars, flags.redactExclude, flags.redactMinLength)
+					stdout := redact.NewWriter(cmd.OutOrStdout(), secrets)
+					stderr := redact.NewWriter(cmd.ErrOrStderr(), secrets)
+					redactors = append(redactors, stdout, stderr)
+					commandToRun.Stdout = stdout
+					commandToRun.Stderr = stderr
+				}
+				return commandToRun, nil
+			}
+
+			if !flags.watch {
+				commandToRun, _ := newCmd()
+				return wrapCommandError(proc.Run(commandToRun))
+			}
+			return flags.runWatched(cmd, cmdCfg, newCmd, envVars, func(newVars []envsec.EnvVar) error {
+				envVars = newVars
+				env, err = execEnv(envVars)
+				return err
+			})
 		},
 	}
+	command.Flags().BoolVar(
+		&flags.noShell,
+		"no-shell",
+		false,
+		"execute the command directly instead of through /bin/sh, preserving each argument",
+	)
+	command.Flags().BoolVar(
+		&flags.replace,
+		"replace",
+		false,
+		"replace the envsec process with the command instead of running it as a child",
+	)
+	command.Flags().BoolVar(
+		&flags.redact,
+		"redact",
+		false,
+		"replace stored values in the output of the command with *****",
+	)
+	command.Flags().StringSliceVar(
+		&flags.redactExclude,
+		"redact-exclude",
+		nil,
+		"glob patterns of non-sensitive variables that should not be redacted",
+	)
+	command.Flags().IntVar(
+		&flags.redactMinLength,
+		"redact-min-length",
+		4,
+		"values shorter than this are not redacted, to avoid false positives",
+	)
+	command.Flags().BoolVarP(
+		&flags.watch,
+		"watch",
+		"w",
+		false,
+		"restart the command when the stored variables change",
+	)
+	command.Flags().DurationVar(
+		&flags.watchInterval,
+		"watch-interval",
+		10*time.Second,
+		"how often to check for changes in watch mode",
+	)
+	command.Flags().StringVar(
+		&flags.restartSignal,
+		"restart-signal",
+		"TERM",
+		"signal used to stop the command before restarting it",
+	)
+	command.Flags().DurationVar(
+		&flags.gracePeriod,
+		"grace-period",
+		10*time.Second,
+		"how long to wait for the command to stop before killing it",
+	)
+	command.Flags().BoolVar(
+		&flags.reload,
+		"reload",
+		false,
+		"in watch mode, send --reload-signal instead of restarting the command",
+	)
+	command.Flags().StringVar(
+		&flags.reloadSignal,
+		"reload-signal",
+		"HUP",
+		"signal sent to the command when variables change and --reload is set",
+	)
+	command.Flags().StringArrayVar(
+		&flags.files,
+		"file",
+		nil,
+		"write variable NAME to a file and set NAME_FILE to its path. Format: NAME[:path]",
+	)
+	command.Flags().StringVar(
+		&flags.fileManifest,
+		"file-manifest",
+		"",
+		"JSON, YAML or TOML file mapping variable names to file paths, as with --file",
+	)
+	command.Flags().BoolVar(
+		&flags.noInherit,
+		"no-inherit",
+		false,
+		"don't pass local environment variables to the command, except those matching --inherit",
+	)
+	command.Flags().StringSliceVar(
+		&flags.inherit,
+		"inherit",
+		envsec.DefaultInheritedVars,
+		"glob patterns of local variables passed to the command with --no-inherit",
+	)
+	command.Flags().StringSliceVar(
+		&flags.only,
+		"only",
+		nil,
+		"glob patterns of the stored variables to pass to the command",
+	)
+	command.Flags().StringSliceVar(
+		&flags.exclude,
+		"exclude",
+		nil,
+		"glob patterns of stored variables not to pass to the command",
+	)
+	command.Flags().StringVar(
+		&flags.prefix,
+		"prefix",
+		"",
+		"prefix added to the names of stored variables",
+	)
+	command.Flags().StringVar(
+		&flags.precedence,
+		"precedence",
+		precedenceRemote,
+		"which value wins when a variable is set both locally and remotely, one of: remote, local",
+	)
+	flags.registerLocalFile(command)
+	flags.registerBinary(command)
 	flags.register(command)
 	return command
 }
+
+// secretFiles returns the files requested with --file and --file-manifest, or
+// nil if there are none.
+func (f *execCmdFlags) secretFiles() (*envsec.SecretFiles, error) {
+	mapping, err := envsec.ParseFileMappings(f.files)
+	if err != nil {
+		return nil, err
+	}
+	if f.fileManifest != "" {
+		manifest, err := envsec.LoadFileManifest(f.fileManifest)
+		if err != nil {
+			return nil, err
+		}
+		for name, path := range manifest {
+			if _, ok := mapping[name]; !ok {
+				mapping[name] = path
+			}
+		}
+	}
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+	return envsec.NewSecretFiles(mapping)
+}
+
+// runWatched runs the command under a supervisor and restarts or signals it
+// whenever the stored variables change. update is called with the new
+// variables before the command is restarted.
+func (f *execCmdFlags) runWatched(
+	cmd *cobra.Command,
+	cmdCfg *CmdConfig,
+	newCmd func() (*exec.Cmd, error),
+	envVars []envsec.EnvVar,
+	update func(envVars []envsec.EnvVar) error,
+) error {
+	restartSignal, _ := proc.ParseSignal(f.restartSignal)
+	reloadSignal, _ := proc.ParseSignal(f.reloadSignal)
+
+	var mu sync.Mutex
+	supervisor := proc.NewSupervisor(func() (*exec.Cmd, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return newCmd()
+	}, restartSignal, f.gracePeriod)
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	go func() {
+		cmdCfg.envsec.Watch(ctx, envVars, f.watchInterval,
+			func(newVars []envsec.EnvVar, changed []string) {
+				action := "Restarting command"
+				if f.reload {
+					action = "Sending " + f.reloadSignal + " to command"
+				}
+				_ = tux.WriteHeader(cmd.ErrOrStderr(),
+					"[envsec] Changed %s %s. %s\n",
+					tux.Plural(changed, "variable", "variables"),
+					strings.Join(tux.QuotedTerms(changed), ", "),
+					action,
+				)
+				if f.reload {
+					supervisor.Signal(reloadSignal)
+					return
+				}
+				mu.Lock()
+				err := update(newVars)
+				mu.Unlock()
+				if err != nil {
+					_ = tux.WriteHeader(cmd.ErrOrStderr(), "[ERROR] %v\n", err)
+					return
+				}
+				supervisor.Restart()
+			})
+	}()
+	return wrapCommandError(supervisor.Run())
+}
+
+// commandError is the error of the command run by exec. Execute exits with
+// the exit status of the command instead of printing it.
+type commandError struct {
+	err error
+}
+
+func wrapCommandError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &commandError{err: err}
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
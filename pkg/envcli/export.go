@@ -0,0 +1,96 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export environment variables as deployment manifests",
+	}
+
+	cmd.AddCommand(exportK8sCmd())
+
+	return cmd
+}
+
+type exportK8sCmdFlags struct {
+	configFlags
+	opts envsec.K8sExportOptions
+}
+
+func exportK8sCmd() *cobra.Command {
+	flags := &exportK8sCmdFlags{}
+	command := &cobra.Command{
+		Use:   "k8s",
+		Short: "Export environment variables as a Kubernetes Secret",
+		Long: "Export environment variables as a Kubernetes Secret, optionally " +
+			"splitting non-sensitive variables into a ConfigMap. Manifests are " +
+			"written to stdout unless --output-dir is set, in which case a " +
+			"kustomization.yaml is generated alongside them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return cmdCfg.envsec.ExportK8s(cmd.Context(), flags.opts)
+		},
+	}
+
+	command.Flags().StringVar(
+		&flags.opts.Name, "name", "", "name of the generated Secret")
+	command.Flags().StringVarP(
+		&flags.opts.Namespace, "namespace", "n", "", "namespace of the generated manifests")
+	command.Flags().StringToStringVar(
+		&flags.opts.Labels, "label", nil, "label to add to the manifests, as key=value")
+	command.Flags().StringToStringVar(
+		&flags.opts.Annotations, "annotation", nil, "annotation to add to the manifests, as key=value")
+	command.Flags().BoolVar(
+		&flags.opts.StringData,
+		"string-data",
+		false,
+		"store values as plain text in stringData instead of base64 encoded data",
+	)
+	command.Flags().StringSliceVar(
+		&flags.opts.ConfigMapKeys,
+		"configmap-keys",
+		nil,
+		"glob patterns of non-sensitive variables to put in a ConfigMap instead",
+	)
+	command.Flags().StringVar(
+		&flags.opts.ConfigMapName,
+		"configmap-name",
+		"",
+		"name of the generated ConfigMap (default \"<name>-config\")",
+	)
+	command.Flags().StringVarP(
+		&flags.opts.OutputDir,
+		"output-dir",
+		"o",
+		"",
+		"directory to write the manifests and a kustomization.yaml to",
+	)
+	command.Flags().StringVar(
+		&flags.opts.SealedSecretsCert,
+		"sealed-secrets-cert",
+		"",
+		"public certificate of a sealed-secrets controller. Generates a SealedSecret",
+	)
+	command.Flags().StringVar(
+		&flags.opts.SealedSecretsScope,
+		"sealed-secrets-scope",
+		"strict",
+		"scope of the SealedSecret, one of: strict, namespace-wide, cluster-wide",
+	)
+	_ = command.MarkFlagRequired("name")
+	flags.register(command)
+
+	return command
+}
@@ -6,8 +6,10 @@ package envcli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/internal/build"
 	"go.jetify.com/envsec/pkg/envsec"
@@ -22,6 +24,7 @@ type configFlags struct {
 	projectID string
 	orgID     string
 	envName   string
+	yes       bool
 }
 
 func (f *configFlags) register(cmd *cobra.Command) {
@@ -43,8 +46,63 @@ func (f *configFlags) register(cmd *cobra.Command) {
 		&f.envName,
 		"environment",
 		"dev",
-		"environment name, one of: dev, preview, prod",
+		"environment name, see envsec env ls. A comma separated list, "+
+			"e.g. dev,preview, layers environments with later ones overriding earlier ones",
 	)
+
+	cmd.PersistentFlags().BoolVarP(
+		&f.yes,
+		"yes",
+		"y",
+		false,
+		"don't ask for confirmation before making changes",
+	)
+}
+
+// to be composed into the flags of commands that apply local overrides
+type localFileFlag struct {
+	localFile string
+}
+
+func (f *localFileFlag) registerLocalFile(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&f.localFile,
+		"local-file",
+		envsec.DefaultLocalFile,
+		"dotenv file with local overrides of the stored variables. Set to \"\" to ignore overrides",
+	)
+}
+
+// to be composed into the flags of commands that change several variables
+type transactionFlag struct {
+	transactional bool
+}
+
+func (f *transactionFlag) registerTransaction(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&f.transactional,
+		"transactional",
+		false,
+		"undo all changes if any of them fails",
+	)
+}
+
+// to be composed into the flags of commands that materialize binary values
+type binaryFlag struct {
+	binary string
+}
+
+func (f *binaryFlag) registerBinary(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&f.binary,
+		"binary",
+		string(envsec.EncodingBase64),
+		fmt.Sprintf("how values that aren't text are written, one of: %v", envsec.Encodings),
+	)
+}
+
+func (f *binaryFlag) binaryEncoding() (envsec.Encoding, error) {
+	return envsec.ParseEncoding(f.binary)
 }
 
 func (f *configFlags) validateProjectID(orgID ids.OrgID) (string, error) {
@@ -93,13 +151,9 @@ func (f *configFlags) genConfig(cmd *cobra.Command) (*CmdConfig, error) {
 		return nil, errors.WithStack(err)
 	}
 	envsecInstance := defaultEnvsec(cmd, wd)
+	envsecInstance.AssumeYes = f.yes
 
-	if envvar.Bool("ENVSEC_USE_AWS_STORE") {
-		// Legacy, temporary hack to enable the AWS store
-		envsecInstance.Store = &ssmstore.SSMStore{}
-	} else {
-		envsecInstance.Store = &jetstore.JetpackAPIStore{}
-	}
+	envsecInstance.Store = defaultStore()
 
 	tok, err := envsecInstance.InitForUser(cmd.Context())
 	if err != nil {
@@ -120,14 +174,39 @@ func (f *configFlags) genConfig(cmd *cobra.Command) (*CmdConfig, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	envid, err := envsec.NewEnvID(projectID, f.orgID, f.envName)
+	envNames, err := envsecInstance.EnvironmentNames()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	envName := f.envName
+	if !cmd.Flags().Changed(environmentFlagName) && !lo.Contains(envNames, envName) {
+		// Projects don't need to have a dev environment, but writes must never
+		// land in an environment nobody chose.
+		return nil, errors.Errorf(
+			"this project has no %s environment. Pass --%s with one of: %s",
+			envName,
+			environmentFlagName,
+			strings.Join(envNames, ", "),
+		)
+	}
+
+	// Only the last environment is written to, the ones before it are layers
+	// that it overrides.
+	layers, err := envsecInstance.EnvironmentLayers(strings.Split(envName, ","))
+	if err != nil {
+		return nil, err
+	}
+	if err := envsecInstance.ValidateEnvironments(layers...); err != nil {
+		return nil, err
+	}
+	envid, err := envsec.NewEnvID(projectID, f.orgID, layers[len(layers)-1])
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	envsecInstance.EnvID = envid
+	envsecInstance.Layers = layers[:len(layers)-1]
 
-	envNames := []string{"dev", "prod", "preview"}
 	if cmd.Flags().Changed(environmentFlagName) {
 		envNames = []string{envid.EnvName}
 	}
@@ -138,6 +217,30 @@ func (f *configFlags) genConfig(cmd *cobra.Command) (*CmdConfig, error) {
 	}, nil
 }
 
+// defaultStore returns the store that variables are kept in.
+func defaultStore() envsec.Store {
+	if envvar.Bool("ENVSEC_USE_AWS_STORE") {
+		// Legacy, temporary hack to enable the AWS store
+		return &ssmstore.SSMStore{}
+	}
+	return &jetstore.JetpackAPIStore{}
+}
+
+// ensureVersionedStore fails if the store doesn't track versions of
+// variables, which flag needs. It is checked before any value is read or
+// prompted for, so that users don't type a value only to have it rejected.
+func ensureVersionedStore(flag string) error {
+	store := defaultStore()
+	if bootstrappedConfig != nil {
+		store = bootstrappedConfig.envsec.Store
+	}
+	if _, ok := store.(envsec.VersionedStore); !ok {
+		return errors.Wrapf(envsec.ErrVersionsNotSupported,
+			"--%s is only supported by the AWS Parameter Store", flag)
+	}
+	return nil
+}
+
 var bootstrappedConfig *CmdConfig
 
 // BootstrapConfig is used to set the config for all commands that use genConfig
@@ -0,0 +1,78 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+type generateCmdFlags struct {
+	configFlags
+	length       int
+	charset      string
+	prefix       string
+	skipExisting bool
+	show         bool
+}
+
+func generateCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "generate <NAME>",
+		Short: "Store a randomly generated secret",
+		Long: "Store a cryptographically random value in an environment variable. " +
+			"The value is never printed unless --show is passed, so it doesn't " +
+			"end up in shell history or logs.",
+		Example: "envsec generate SESSION_SECRET --length 64 --charset urlsafe",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.Generate(cmd.Context(), args[0], envsec.GenerateOptions{
+				Length:       flags.length,
+				Charset:      envsec.Charset(flags.charset),
+				Prefix:       flags.prefix,
+				SkipExisting: flags.skipExisting,
+				Show:         flags.show,
+			})
+		},
+	}
+	command.Flags().IntVar(
+		&flags.length,
+		"length",
+		0,
+		"number of characters, or of words with --charset words (default 32, or 6 words)",
+	)
+	command.Flags().StringVar(
+		&flags.charset,
+		"charset",
+		string(envsec.CharsetAlnum),
+		fmt.Sprintf("characters to generate the value from, one of: %v", envsec.Charsets),
+	)
+	command.Flags().StringVar(
+		&flags.prefix,
+		"prefix",
+		"",
+		"text to prepend to the generated value, e.g. sk_",
+	)
+	command.Flags().BoolVar(
+		&flags.skipExisting,
+		"skip-existing",
+		false,
+		"don't change the variable if it is already set",
+	)
+	command.Flags().BoolVar(
+		&flags.show,
+		"show",
+		false,
+		"print the generated value",
+	)
+	flags.register(command)
+	return command
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+type importCmdFlags struct {
+	configFlags
+	transactionFlag
+	from   string
+	dryRun bool
+}
+
+func importCmd() *cobra.Command {
+	flags := &importCmdFlags{}
+	sources := []string{}
+	for _, importer := range envsec.Importers() {
+		sources = append(sources, fmt.Sprintf("  %-10s %s", importer.Name, importer.Description))
+	}
+	command := &cobra.Command{
+		Use:   "import --from <source> <file>",
+		Short: "Import variables exported from another secret manager",
+		Long: "Import variables exported from another secret manager. The variables " +
+			"that would be added or changed are shown and must be confirmed before " +
+			"they are written. Keys that aren't valid names, such as the tls.crt " +
+			"key of a Kubernetes secret, are turned into names like TLS_CRT." +
+			"\n\nSources:\n" + strings.Join(sources, "\n"),
+		Example: "heroku config --json > heroku.json\nenvsec import --from heroku heroku.json",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg.envsec.Transactional = flags.transactional
+			return cmdCfg.envsec.Import(cmd.Context(), args[0], envsec.ImportOptions{
+				From:   flags.from,
+				DryRun: flags.dryRun,
+			})
+		},
+	}
+	command.Flags().StringVar(&flags.from, "from", "", "the secret manager the file was exported from")
+	_ = command.MarkFlagRequired("from")
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"only show the variables that would be imported",
+	)
+	flags.registerTransaction(command)
+	flags.register(command)
+	return command
+}
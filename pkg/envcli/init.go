@@ -1,12 +1,14 @@
 package envcli
 
 import (
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/internal/build"
 	"go.jetify.com/envsec/pkg/envsec"
 	"go.jetify.com/pkg/envvar"
+	"golang.org/x/term"
 )
 
 type initCmdFlags struct {
@@ -42,6 +44,12 @@ func initCmd() *cobra.Command {
 }
 
 func defaultEnvsec(cmd *cobra.Command, workingDir string) *envsec.Envsec {
+	// Confirmations are only read from a terminal, so that piped input never
+	// confirms changes by accident.
+	var stdin io.Reader
+	if f, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		stdin = f
+	}
 	return &envsec.Envsec{
 		APIHost: build.JetpackAPIHost(),
 		Auth: envsec.AuthConfig{
@@ -50,8 +58,11 @@ func defaultEnvsec(cmd *cobra.Command, workingDir string) *envsec.Envsec {
 			Issuer:          envvar.Get("ENVSEC_ISSUER", build.Issuer()),
 			SuccessRedirect: envvar.Get("ENVSEC_SUCCESS_REDIRECT", build.SuccessRedirect()),
 		},
-		IsDev:      build.IsDev,
-		Stderr:     cmd.ErrOrStderr(),
-		WorkingDir: workingDir,
+		IdentityFile: envvar.Get("ENVSEC_IDENTITY_FILE", ""),
+		IsDev:        build.IsDev,
+		Stderr:       cmd.ErrOrStderr(),
+		Stdin:        stdin,
+		Stdout:       cmd.OutOrStdout(),
+		WorkingDir:   workingDir,
 	}
 }
@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+type injectCmdFlags struct {
+	configFlags
+	input    string
+	output   string
+	fileMode string
+}
+
+func injectCmd() *cobra.Command {
+	flags := &injectCmdFlags{}
+	command := &cobra.Command{
+		Use:   "inject",
+		Short: "Replace secret references in a file with their values",
+		Long: "Replace every envsec://<project>/<environment>/<NAME> reference in " +
+			"the input file with the value of the referenced variable. The project " +
+			"is a project ID or name, leave it empty (envsec:///prod/NAME) to use " +
+			"the current project.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			_, err := parseFileMode(flags.fileMode)
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fileMode, err := parseFileMode(flags.fileMode)
+			if err != nil {
+				return err
+			}
+			return cmdCfg.envsec.Inject(cmd.Context(), flags.input, envsec.InjectOptions{
+				Output:   flags.output,
+				FileMode: fileMode,
+			})
+		},
+	}
+
+	command.Flags().StringVarP(
+		&flags.input, "input", "i", "", "file containing secret references")
+	command.Flags().StringVarP(
+		&flags.output, "output", "o", "", "file to write the result to (default stdout)")
+	command.Flags().StringVar(
+		&flags.fileMode, "file-mode", "0600", "permissions of the output file, in octal")
+	_ = command.MarkFlagRequired("input")
+	flags.register(command)
+
+	return command
+}
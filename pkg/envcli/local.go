@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+func localCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "local",
+		Short: "Manage personal overrides of stored environment variables",
+		Long: heredoc.Doc(`
+			Manage personal overrides of stored environment variables.
+
+			Local overrides are kept in a dotenv file, .jetify/local.env by
+			default, which is ignored by git and never uploaded. exec, download
+			and ls apply them on top of the stored values.
+		`),
+	}
+	command.AddCommand(localSetCmd())
+	command.AddCommand(localRemoveCmd())
+	return command
+}
+
+func localSetCmd() *cobra.Command {
+	flags := &localFileFlag{}
+	command := &cobra.Command{
+		Use:   "set <NAME1>=<value1> [<NAME2>=<value2>]...",
+		Short: "Override one or more environment variables locally",
+		Args:  cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return envsec.ValidateSetArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := flags.localEnvsec(cmd)
+			if err != nil {
+				return err
+			}
+			values := map[string]string{}
+			for _, arg := range args {
+				name, value, _ := strings.Cut(arg, "=")
+				values[name] = value
+			}
+			return e.SetLocal(values)
+		},
+	}
+	flags.registerLocalFile(command)
+	return command
+}
+
+func localRemoveCmd() *cobra.Command {
+	flags := &localFileFlag{}
+	command := &cobra.Command{
+		Use:   "rm <NAME1> [<NAME2>]...",
+		Short: "Delete one or more local overrides",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := flags.localEnvsec(cmd)
+			if err != nil {
+				return err
+			}
+			return e.DeleteLocal(args...)
+		},
+	}
+	flags.registerLocalFile(command)
+	return command
+}
+
+// localEnvsec returns an Envsec for managing local overrides, which don't
+// require a login or an initialized project.
+func (f *localFileFlag) localEnvsec(cmd *cobra.Command) (*envsec.Envsec, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	e := defaultEnvsec(cmd, wd)
+	e.LocalFile = f.localFile
+	return e, nil
+}
@@ -4,6 +4,10 @@
 package envcli
 
 import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/pkg/envsec"
 )
@@ -12,8 +16,10 @@ const environmentFlagName = "environment"
 
 type listCmdFlags struct {
 	configFlags
+	localFileFlag
 	ShowValues bool
 	Format     string
+	Versions   bool
 }
 
 func ListCmd() *cobra.Command {
@@ -31,11 +37,43 @@ func ListCmd() *cobra.Command {
 				return err
 			}
 
-			secrets, err := cmdCfg.envsec.List(cmd.Context())
+			cmdCfg.envsec.LocalFile = flags.localFile
+
+			if flags.Versions {
+				if flags.Format != "table" {
+					return errors.New("--versions can only be used with the table format")
+				}
+				versions, err := cmdCfg.envsec.ListVersions(cmd.Context())
+				if err != nil {
+					return err
+				}
+				secrets, err := cmdCfg.envsec.List(cmd.Context())
+				if err != nil {
+					return err
+				}
+				return envsec.PrintVersionedEnvVars(cmd.OutOrStdout(),
+					cmdCfg.envsec.EnvID, secrets, versions, flags.ShowValues)
+			}
+
+			layered, err := cmdCfg.envsec.ListLayered(cmd.Context())
 			if err != nil {
 				return err
 			}
+			hasOverrides := lo.ContainsBy(layered, func(envVar envsec.LayeredEnvVar) bool {
+				return envVar.Layer == envsec.LocalLayer
+			})
+			if len(cmdCfg.envsec.Layers) > 0 || hasOverrides {
+				layers := cmdCfg.envsec.LayerNames()
+				if hasOverrides {
+					layers = append(layers, envsec.LocalLayer)
+				}
+				return envsec.PrintLayeredEnvVars(
+					cmd.OutOrStdout(), layers, layered, flags.ShowValues, flags.Format)
+			}
 
+			secrets := lo.Map(layered, func(envVar envsec.LayeredEnvVar, _ int) envsec.EnvVar {
+				return envVar.EnvVar
+			})
 			return envsec.PrintEnvVar(
 				cmd.OutOrStdout(), cmdCfg.envsec.EnvID, secrets, flags.ShowValues, flags.Format)
 		},
@@ -53,8 +91,17 @@ func ListCmd() *cobra.Command {
 		"format",
 		"f",
 		"table",
-		"format to use for displaying keys and values, one of: table, dotenv, json",
+		"format to use for displaying keys and values, one of: table, "+
+			strings.Join(envsec.FormatNames(), ", "),
+	)
+	command.Flags().BoolVar(
+		&flags.Versions,
+		"versions",
+		false,
+		"show the version of each variable, for use with set --if-version. "+
+			"Only supported by the AWS Parameter Store",
 	)
+	flags.registerLocalFile(command)
 	flags.register(command)
 
 	return command
@@ -0,0 +1,142 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"net/url"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+	"go.jetify.com/envsec/pkg/stores/jetstore"
+	"go.jetify.com/envsec/pkg/stores/ssmstore"
+)
+
+type migrateCmdFlags struct {
+	configFlags
+	from         string
+	to           string
+	deleteSource bool
+}
+
+func migrateCmd() *cobra.Command {
+	flags := &migrateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "migrate --from <store> --to <store>",
+		Short: "Copy all environments of the project to another store",
+		Long: heredoc.Doc(`
+			Copy the variables of all environments of the project from one store
+			to another, and verify them by reading them back. Stores are given as
+			ssm:// for AWS Parameter Store, or jetify:// for the Jetify API.
+			Parameter Store can be given a region, as in ssm://us-east-1, to copy
+			between regions.
+
+			Variables with empty values are copied, but stores that don't keep
+			empty values, such as the Jetify API, leave them unset. They are
+			listed in a warning.
+
+			Progress is recorded in .jetify/migration.json. If the migration
+			fails, run the same command again to resume it. With --delete-source,
+			the variables are deleted from the source store after all of them
+			have been verified.
+		`),
+		Example: heredoc.Doc(`
+			envsec migrate --from ssm:// --to jetify://
+			envsec migrate --from ssm://us-east-1 --to ssm://eu-west-1
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := storeFromURI(flags.from)
+			if err != nil {
+				return errors.Wrap(err, "invalid --from")
+			}
+			to, err := storeFromURI(flags.to)
+			if err != nil {
+				return errors.Wrap(err, "invalid --to")
+			}
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			for _, store := range []envsec.Store{from, to} {
+				if _, err := store.InitForUser(cmd.Context(), cmdCfg.envsec); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			// ssm:// and ssm://<default region> are the same store, which
+			// would pass verification trivially and be deleted with
+			// --delete-source.
+			if sameStore(from, to) {
+				return errors.New("--from and --to must be different stores")
+			}
+			cmdCfg.envsec.Store = from
+			envNames := cmdCfg.envNames
+			if !cmd.Flags().Changed(environmentFlagName) {
+				// Include environments that have variables in the source store
+				// but aren't listed in the project.
+				if envNames, err = cmdCfg.envsec.AllEnvironmentNames(cmd.Context()); err != nil {
+					return err
+				}
+			}
+			return cmdCfg.envsec.Migrate(cmd.Context(), envsec.MigrateOptions{
+				From:         flags.from,
+				To:           flags.to,
+				Target:       to,
+				Environments: envNames,
+				DeleteSource: flags.deleteSource,
+			})
+		},
+	}
+	command.Flags().StringVar(&flags.from, "from", "", "store to copy from: ssm://[region] or jetify://")
+	command.Flags().StringVar(&flags.to, "to", "", "store to copy to: ssm://[region] or jetify://")
+	_ = command.MarkFlagRequired("from")
+	_ = command.MarkFlagRequired("to")
+	command.Flags().BoolVar(
+		&flags.deleteSource,
+		"delete-source",
+		false,
+		"delete the variables from the source store once all of them are verified",
+	)
+	flags.register(command)
+	return command
+}
+
+// storeFromURI returns an uninitialized store for a URI such as ssm:// or
+// ssm://us-east-1, whose host is the AWS region.
+func storeFromURI(uri string) (envsec.Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil || u.Port() != "" {
+		return nil, errors.Errorf("store %q only takes a region", uri)
+	}
+	switch u.Scheme {
+	case "ssm":
+		return &ssmstore.SSMStore{Region: u.Hostname()}, nil
+	case "jetify":
+		if u.Host != "" {
+			return nil, errors.Errorf("store %q does not take any options", uri)
+		}
+		return &jetstore.JetpackAPIStore{}, nil
+	default:
+		return nil, errors.Errorf("unknown store %q. Must be ssm://[region] or jetify://", uri)
+	}
+}
+
+// sameStore reports whether a and b hold the same variables, comparing the
+// regions of Parameter Store stores once they are initialized.
+func sameStore(a, b envsec.Store) bool {
+	switch a := a.(type) {
+	case *ssmstore.SSMStore:
+		b, ok := b.(*ssmstore.SSMStore)
+		return ok && a.EffectiveRegion() == b.EffectiveRegion()
+	case *jetstore.JetpackAPIStore:
+		_, ok := b.(*jetstore.JetpackAPIStore)
+		return ok
+	default:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"testing"
+
+	"go.jetify.com/envsec/pkg/stores/ssmstore"
+)
+
+func TestStoreFromURI(t *testing.T) {
+	store, err := storeFromURI("ssm://us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssm, ok := store.(*ssmstore.SSMStore); !ok || ssm.Region != "us-east-1" {
+		t.Errorf("Expected an SSM store in us-east-1, but got %#v", store)
+	}
+	for _, uri := range []string{"ssm://us-east-1/path", "jetify://host", "vault://"} {
+		if _, err := storeFromURI(uri); err == nil {
+			t.Errorf("Expected %s to be rejected", uri)
+		}
+	}
+}
+
+func TestSameStore(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{"jetify://", "jetify://", true},
+		{"ssm://us-east-1", "ssm://us-east-1", true},
+		{"ssm://us-east-1", "ssm://eu-west-1", false},
+		{"ssm://us-east-1", "jetify://", false},
+	}
+	for _, test := range tests {
+		from, err := storeFromURI(test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := storeFromURI(test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sameStore(from, to); got != test.expected {
+			t.Errorf("sameStore(%s, %s) = %t, expected %t", test.from, test.to, got, test.expected)
+		}
+	}
+}
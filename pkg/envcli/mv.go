@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+	"go.jetify.com/pkg/ids"
+)
+
+type moveCmdFlags struct {
+	configFlags
+	transactionFlag
+	allEnvs   bool
+	toProject string
+	force     bool
+}
+
+func moveCmd() *cobra.Command {
+	flags := &moveCmdFlags{}
+	command := &cobra.Command{
+		Use:   "mv <OLD> <NEW>",
+		Short: "Rename an environment variable",
+		Long: heredoc.Doc(`
+			Rename an environment variable by copying its value to NEW and
+			deleting OLD. With --all-envs it is renamed in every environment it
+			is set in, and with --to-project it is moved to the same environments
+			of another project.
+
+			No store can rename a variable atomically, so NEW is set before OLD
+			is deleted, and a failure never loses the value. If a step fails,
+			the error lists the environments that were moved, the ones that
+			weren't, and whether OLD is left next to NEW. With --transactional,
+			a failure to delete OLD undoes setting NEW instead. The progress is
+			recorded in .jetify/move.json, and running the same command again
+			finishes the move.
+		`),
+		Example: heredoc.Doc(`
+			envsec mv DB_PASS DATABASE_PASSWORD --all-envs
+			envsec mv STRIPE_KEY STRIPE_KEY --to-project proj_... --environment prod
+		`),
+		Args: cobra.ExactArgs(2),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.toProject == "" {
+				return nil
+			}
+			_, err := ids.ParseProjectID(flags.toProject)
+			return errors.Wrap(err, "invalid --to-project")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			cmdCfg.envsec.Transactional = flags.transactional
+			return cmdCfg.envsec.Move(cmd.Context(), args[0], args[1], envsec.MoveOptions{
+				AllEnvironments: flags.allEnvs,
+				ToProject:       flags.toProject,
+				Force:           flags.force,
+			})
+		},
+	}
+	command.Flags().BoolVar(
+		&flags.allEnvs,
+		"all-envs",
+		false,
+		"rename the variable in every environment of the project",
+	)
+	command.Flags().StringVar(
+		&flags.toProject,
+		"to-project",
+		"",
+		"id of the project to move the variable to",
+	)
+	command.Flags().BoolVar(
+		&flags.force,
+		"force",
+		false,
+		"overwrite NEW if it is already set to another value",
+	)
+	flags.registerTransaction(command)
+	flags.register(command)
+	return command
+}
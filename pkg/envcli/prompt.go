@@ -0,0 +1,40 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// promptSecret reads a secret from the terminal without echoing it. If
+// confirm is set, the secret must be typed twice.
+func promptSecret(cmd *cobra.Command, prompt string, confirm bool) (string, error) {
+	f, ok := cmd.InOrStdin().(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: ", prompt)
+		secret, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(cmd.ErrOrStderr())
+		return string(secret), errors.WithStack(err)
+	}
+	secret, err := read(prompt)
+	if err != nil || !confirm {
+		return secret, err
+	}
+	again, err := read("Repeat to confirm")
+	if err != nil {
+		return "", err
+	}
+	if again != secret {
+		return "", errors.New("the values did not match")
+	}
+	return secret, nil
+}
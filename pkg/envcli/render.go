@@ -0,0 +1,80 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+type renderCmdFlags struct {
+	configFlags
+	output   string
+	fileMode string
+	watch    bool
+	interval time.Duration
+}
+
+func renderCmd() *cobra.Command {
+	flags := &renderCmdFlags{}
+	command := &cobra.Command{
+		Use:   "render <template>",
+		Short: "Render a template file with the stored environment variables",
+		Long: heredoc.Doc(`
+			Render a Go text/template file with the stored environment variables.
+
+			Variables are available as {{ .NAME }}. The following functions are
+			also available: env, default, required, b64enc, json and quote, e.g.
+			{{ env "PORT" | default "8080" }}. Rendering fails if a variable
+			referenced with {{ .NAME }} or required is missing.
+		`),
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.interval <= 0 {
+				return errors.New("--interval must be positive")
+			}
+			_, err := parseFileMode(flags.fileMode)
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fileMode, err := parseFileMode(flags.fileMode)
+			if err != nil {
+				return err
+			}
+			opts := envsec.RenderOptions{
+				Output:   flags.output,
+				FileMode: fileMode,
+			}
+			if flags.watch {
+				return cmdCfg.envsec.WatchRender(cmd.Context(), args[0], opts, flags.interval)
+			}
+			return cmdCfg.envsec.Render(cmd.Context(), args[0], opts)
+		},
+	}
+
+	command.Flags().StringVarP(
+		&flags.output, "output", "o", "", "file to write the result to (default stdout)")
+	command.Flags().StringVar(
+		&flags.fileMode, "file-mode", "0600", "permissions of the rendered file, in octal")
+	command.Flags().BoolVarP(
+		&flags.watch,
+		"watch",
+		"w",
+		false,
+		"keep running and render again when the template or the variables change",
+	)
+	command.Flags().DurationVar(
+		&flags.interval, "interval", 5*time.Second, "how often to check for changes in watch mode")
+	flags.register(command)
+
+	return command
+}
@@ -10,6 +10,7 @@ import (
 
 type removeCmdFlags struct {
 	configFlags
+	transactionFlag
 }
 
 func RemoveCmd() *cobra.Command {
@@ -24,9 +25,11 @@ func RemoveCmd() *cobra.Command {
 			if err != nil {
 				return errors.WithStack(err)
 			}
+			cmdCfg.envsec.Transactional = flags.transactional
 			return cmdCfg.envsec.DeleteAll(cmd.Context(), envNames...)
 		},
 	}
+	flags.registerTransaction(command)
 	flags.register(command)
 
 	return command
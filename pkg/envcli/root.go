@@ -11,7 +11,9 @@ import (
 	"os"
 
 	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/internal/proc"
 )
 
 type rootCmdFlags struct {
@@ -52,14 +54,26 @@ func RootCmd(flags *rootCmdFlags) *cobra.Command {
 	command.Flag("json-errors").Hidden = true
 
 	command.AddCommand(authCmd())
+	command.AddCommand(backupCmd())
 	command.AddCommand(DownloadCmd())
+	command.AddCommand(envCmd())
 	command.AddCommand(ExecCmd())
+	command.AddCommand(exportCmd())
+	command.AddCommand(generateCmd())
 	command.AddCommand(genDocsCmd())
+	command.AddCommand(importCmd())
 	command.AddCommand(initCmd())
+	command.AddCommand(injectCmd())
 	command.AddCommand(ListCmd())
+	command.AddCommand(localCmd())
 	command.AddCommand(infoCmd())
+	command.AddCommand(migrateCmd())
+	command.AddCommand(moveCmd())
 	command.AddCommand(RemoveCmd())
+	command.AddCommand(renderCmd())
+	command.AddCommand(restoreCmd())
 	command.AddCommand(SetCmd())
+	command.AddCommand(snapshotCmd())
 	command.AddCommand(UploadCmd())
 	command.AddCommand(versionCmd())
 	command.SetUsageFunc(UsageFunc)
@@ -73,6 +87,15 @@ func Execute(ctx context.Context) int {
 	if err == nil {
 		return 0
 	}
+	// A command run by exec failed. Its output already explains why, so we
+	// only propagate its exit status. Other processes, such as credential
+	// helpers, failing is reported like any other error.
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		if code, ok := proc.ExitCode(cmdErr.err); ok {
+			return code
+		}
+	}
 	if flags.jsonErrors {
 		var jsonErr struct {
 			Error string `json:"error"`
@@ -4,35 +4,200 @@
 package envcli
 
 import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/pkg/envsec"
+	"golang.org/x/term"
 )
 
+// stdinValue is the value of NAME=- args, which read the value from stdin.
+const stdinValue = "-"
+
 type setCmdFlags struct {
 	configFlags
+	transactionFlag
+	ifVersion   string
+	trimNewline bool
+	allowEmpty  bool
 }
 
 func SetCmd() *cobra.Command {
 	flags := &setCmdFlags{}
 	command := &cobra.Command{
-		Use:   "set <NAME1>=<value1> [<NAME2>=<value2>]...",
+		Use:   "set <NAME1>[=<value1>] [<NAME2>=<value2>]...",
 		Short: "Securely store one or more environment variables",
-		Long:  "Securely store one or more environment variables. To test contents of a file as a secret use set=@<file>",
-		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Securely store one or more environment variables. Names must start
+			with a letter or underscore and contain only letters, digits and
+			underscores. Earlier versions also accepted names such as API-KEY,
+			which shells can't export. Variables that already have such names
+			can still be read, deleted, or renamed with envsec mv.
+
+			To set a variable to the contents of a file use NAME=@<file>. Binary
+			files, such as certificates, are stored byte for byte. To keep
+			a value out of shell history, pass only its NAME: it is prompted for
+			without echoing it when run in a terminal, and read from stdin
+			otherwise. NAME=- always reads the value from stdin. An empty answer
+			or empty stdin is rejected unless --allow-empty is passed, so that a
+			mistake doesn't clear the value.
+		`),
+		Example: heredoc.Doc(`
+			envsec set API_KEY
+			pbpaste | envsec set API_KEY --trim-newline
+			envsec set TLS_KEY=- < key.pem
+		`),
+		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return envsec.ValidateSetArgs(args)
+			if flags.ifVersion != "" && len(args) != 1 {
+				return errors.New("--if-version can only be used when setting a single variable")
+			}
+			if flags.ifVersion != "" {
+				if err := ensureVersionedStore("if-version"); err != nil {
+					return err
+				}
+			}
+			valueArgs, stdinArg, err := splitStdinArg(args)
+			if err != nil {
+				return err
+			}
+			if stdinArg == "" && flags.trimNewline {
+				return errors.New("--trim-newline can only be used when reading a value from stdin")
+			}
+			if stdinArg == "" && flags.allowEmpty {
+				return errors.New("--allow-empty can only be used when reading a value from stdin")
+			}
+			if err := envsec.ValidateSetArgs(valueArgs); err != nil {
+				return err
+			}
+			// Check the names before a value is prompted for or read from
+			// stdin, including the name of that value.
+			names := lo.Map(args, func(arg string, _ int) string {
+				name, _, _ := strings.Cut(arg, "=")
+				return name
+			})
+			return envsec.ValidateNames(names...)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			valueArgs, stdinArg, err := splitStdinArg(args)
+			if err != nil {
+				return err
+			}
+			envMap, err := envsec.ParseSetArgs(valueArgs)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if stdinArg != "" {
+				// Read the value before logging in, which may prompt too. Only
+				// NAME prompts, NAME=- always reads stdin.
+				name, _, explicit := strings.Cut(stdinArg, "=")
+				if envMap[name], err = flags.readValue(cmd, name, !explicit); err != nil {
+					return err
+				}
+			}
+
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
 				return errors.WithStack(err)
 			}
+			cmdCfg.envsec.Transactional = flags.transactional
 
-			return cmdCfg.envsec.SetFromArgs(ctx, args)
+			cond := envsec.WriteConditions{}
+			if flags.ifVersion != "" {
+				name, _, _ := strings.Cut(args[0], "=")
+				cond.IfVersion = map[string]string{name: flags.ifVersion}
+			}
+			return cmdCfg.envsec.SetMapIf(ctx, envMap, cond)
 		},
 	}
+	command.Flags().StringVar(
+		&flags.ifVersion,
+		"if-version",
+		"",
+		"fail without writing unless the variable is still at this version "+
+			"(see ls --versions). Use "+envsec.NoVersion+" to require that it doesn't exist yet. "+
+			"Only supported by the AWS Parameter Store",
+	)
+	command.Flags().BoolVar(
+		&flags.trimNewline,
+		"trim-newline",
+		false,
+		"remove a single trailing newline from a value read from stdin",
+	)
+	command.Flags().BoolVar(
+		&flags.allowEmpty,
+		"allow-empty",
+		false,
+		"allow the value read from stdin or the prompt to be empty",
+	)
+	flags.registerTransaction(command)
 	flags.register(command)
 	return command
 }
+
+// splitStdinArg separates the arg whose value is read from stdin, either
+// NAME or NAME=-, from the NAME=VALUE args. Only one value can be read from
+// stdin.
+func splitStdinArg(args []string) ([]string, string, error) {
+	valueArgs := []string{}
+	stdinArg := ""
+	stdinName := ""
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if ok && value != stdinValue {
+			valueArgs = append(valueArgs, arg)
+			continue
+		}
+		if name == "" {
+			return nil, "", errors.Errorf("argument %s must have a name", arg)
+		}
+		if stdinName != "" {
+			return nil, "", errors.Errorf(
+				"only one value can be read from stdin, but both %s and %s read it",
+				stdinName,
+				name,
+			)
+		}
+		stdinArg = arg
+		stdinName = name
+	}
+	return valueArgs, stdinArg, nil
+}
+
+// readValue reads the value of name. If prompt is set and stdin is a
+// terminal, the value is prompted for without echoing it. Otherwise all of
+// stdin is read, as is. Empty values are rejected unless allowEmpty is set.
+func (f *setCmdFlags) readValue(cmd *cobra.Command, name string, prompt bool) (string, error) {
+	value, err := f.readRawValue(cmd, name, prompt)
+	if err != nil {
+		return "", err
+	}
+	if value == "" && !f.allowEmpty {
+		return "", errors.Errorf("empty value for %s. Pass --allow-empty to set it to an empty value", name)
+	}
+	return value, nil
+}
+
+func (f *setCmdFlags) readRawValue(cmd *cobra.Command, name string, prompt bool) (string, error) {
+	stdin := cmd.InOrStdin()
+	if file, ok := stdin.(*os.File); ok && prompt && term.IsTerminal(int(file.Fd())) {
+		return promptSecret(cmd, "Value of "+name, true /*confirm*/)
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the value of %s from stdin", name)
+	}
+	value := string(data)
+	if f.trimNewline && strings.HasSuffix(value, "\r\n") {
+		value = strings.TrimSuffix(value, "\r\n")
+	} else if f.trimNewline {
+		value = strings.TrimSuffix(value, "\n")
+	}
+	return value, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+func TestSplitStdinArg(t *testing.T) {
+	valueArgs, stdinArg, err := splitStdinArg([]string{"A=1", "B", "C=@file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdinArg != "B" || len(valueArgs) != 2 {
+		t.Errorf("Expected B to read stdin, but got %q and %v", stdinArg, valueArgs)
+	}
+	if _, stdinArg, _ := splitStdinArg([]string{"A=-"}); stdinArg != "A=-" {
+		t.Errorf("Expected A=- to read stdin, but got %q", stdinArg)
+	}
+	if _, _, err := splitStdinArg([]string{"A", "B=-"}); err == nil {
+		t.Error("Expected an error when two values read stdin")
+	}
+}
+
+func TestReadValueFromStdin(t *testing.T) {
+	binary := "\x00\xff line one\r\nline two\r\n"
+	cmd := &cobra.Command{}
+	cmd.SetIn(bytes.NewBufferString(binary))
+	flags := &setCmdFlags{}
+	value, err := flags.readValue(cmd, "A", true /*prompt*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != binary {
+		t.Errorf("Expected stdin to be read as is, but got %q", value)
+	}
+
+	cmd.SetIn(bytes.NewBufferString(binary))
+	flags.trimNewline = true
+	if value, _ := flags.readValue(cmd, "A", false); value != binary[:len(binary)-2] {
+		t.Errorf("Expected a single trailing newline to be trimmed, but got %q", value)
+	}
+}
+
+func TestReadValueRejectsEmpty(t *testing.T) {
+	cmd := &cobra.Command{}
+	flags := &setCmdFlags{trimNewline: true}
+	for _, input := range []string{"", "\n"} {
+		cmd.SetIn(bytes.NewBufferString(input))
+		_, err := flags.readValue(cmd, "A", false)
+		if err == nil || !strings.Contains(err.Error(), "empty value for A") {
+			t.Errorf("Expected %q to be rejected as empty, but got %v", input, err)
+		}
+	}
+
+	cmd.SetIn(bytes.NewBufferString(""))
+	flags.allowEmpty = true
+	if value, err := flags.readValue(cmd, "A", false); err != nil || value != "" {
+		t.Errorf("Expected an empty value with --allow-empty, but got %q, %v", value, err)
+	}
+}
+
+func TestSetChecksVersionsBeforeReading(t *testing.T) {
+	t.Setenv("ENVSEC_USE_AWS_STORE", "")
+	stdin := bytes.NewBufferString("secret")
+	cmd := SetCmd()
+	cmd.SetIn(stdin)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"A", "--if-version", "1"})
+	if err := cmd.Execute(); !errors.Is(err, envsec.ErrVersionsNotSupported) {
+		t.Errorf("Expected ErrVersionsNotSupported, but got %v", err)
+	}
+	if stdin.Len() == 0 {
+		t.Error("Expected the value not to be read")
+	}
+}
+
+func TestSetValidatesNameBeforeReading(t *testing.T) {
+	stdin := bytes.NewBufferString("secret")
+	cmd := SetCmd()
+	cmd.SetIn(stdin)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"bad-name"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "name bad-name must match") {
+		t.Errorf("Expected bad-name to be rejected, but got %v", err)
+	}
+	if stdin.Len() == 0 {
+		t.Error("Expected the value not to be read")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetify.com/envsec/internal/tux"
+	"go.jetify.com/envsec/pkg/envsec"
+)
+
+func snapshotCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore copies of all the variables of an environment",
+		Long: heredoc.Doc(`
+			Save and restore copies of all the variables of an environment.
+
+			Snapshots are encrypted with an age identity that is created in the
+			user config directory the first time it is needed, so by default
+			only that identity can restore them. To share a snapshot with your
+			team, or to keep it if that file is lost, encrypt it to other age
+			recipients with --recipient, or to a passphrase with --passphrase,
+			like envsec backup does. Snapshots are kept in the .jetify
+			directory of the project, or in the store itself with
+			--storage remote. A remote snapshot is a single value of the store,
+			so stores that limit the size of values, such as AWS Parameter
+			Store with 4 KB, can only hold snapshots of small environments.
+		`),
+	}
+	command.AddCommand(snapshotCreateCmd())
+	command.AddCommand(snapshotListCmd())
+	command.AddCommand(snapshotRestoreCmd())
+	return command
+}
+
+type snapshotCreateCmdFlags struct {
+	configFlags
+	recipientFlags
+	name    string
+	storage string
+}
+
+func snapshotCreateCmd() *cobra.Command {
+	flags := &snapshotCreateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "create",
+		Short: "Snapshot all the variables of an environment",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			storage := envsec.SnapshotStorage(flags.storage)
+			if storage != envsec.SnapshotStorageLocal && storage != envsec.SnapshotStorageRemote {
+				return errors.Errorf("--storage must be %s or %s",
+					envsec.SnapshotStorageLocal, envsec.SnapshotStorageRemote)
+			}
+			recipients, err := flags.parseRecipients(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			_, err = cmdCfg.envsec.CreateSnapshot(cmd.Context(), flags.name, storage, recipients...)
+			return err
+		},
+	}
+	command.Flags().StringVar(
+		&flags.name,
+		"name",
+		"",
+		"name of the snapshot, which can be used instead of its ID",
+	)
+	command.Flags().StringVar(
+		&flags.storage,
+		"storage",
+		string(envsec.SnapshotStorageLocal),
+		"where to keep the snapshot: local, in the project's .jetify directory, or remote, "+
+			"in the store, subject to its limit on the size of values",
+	)
+	flags.registerRecipients(command, "snapshot")
+	flags.register(command)
+	return command
+}
+
+type snapshotListCmdFlags struct {
+	configFlags
+}
+
+func snapshotListCmd() *cobra.Command {
+	flags := &snapshotListCmdFlags{}
+	command := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List the snapshots of an environment",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			snapshots, err := cmdCfg.envsec.ListSnapshots(cmd.Context())
+			if err != nil {
+				return err
+			}
+			rows := [][]string{{"ID", "NAME", "CREATED", "VARIABLES", "STORAGE"}}
+			for _, snapshot := range snapshots {
+				rows = append(rows, []string{
+					snapshot.ID,
+					snapshot.Name,
+					snapshot.CreatedAt.Local().Format(time.DateTime),
+					strconv.Itoa(snapshot.Variables),
+					string(snapshot.Storage),
+				})
+			}
+			return tux.FTable(cmd.OutOrStdout(), rows)
+		},
+	}
+	flags.register(command)
+	return command
+}
+
+type snapshotRestoreCmdFlags struct {
+	configFlags
+	identityFlags
+	transactionFlag
+	prune bool
+}
+
+func snapshotRestoreCmd() *cobra.Command {
+	flags := &snapshotRestoreCmdFlags{}
+	command := &cobra.Command{
+		Use:   "restore <id-or-name>",
+		Short: "Restore the variables of an environment from a snapshot",
+		Long: "Restore the variables of an environment from a snapshot. The changes " +
+			"are shown and must be confirmed before they are made.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			identities, err := flags.parseIdentities(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return err
+			}
+			cmdCfg.envsec.Transactional = flags.transactional
+			return cmdCfg.envsec.RestoreSnapshot(cmd.Context(), args[0], flags.prune, identities...)
+		},
+	}
+	command.Flags().BoolVar(
+		&flags.prune,
+		"prune",
+		false,
+		"delete variables that are not in the snapshot",
+	)
+	flags.registerIdentities(command, "snapshot")
+	flags.registerTransaction(command)
+	flags.register(command)
+	return command
+}
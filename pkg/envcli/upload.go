@@ -4,13 +4,20 @@
 package envcli
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.jetify.com/envsec/pkg/envsec"
 )
 
 type uploadCmdFlags struct {
 	configFlags
-	format string
+	transactionFlag
+	format           string
+	separator        string
+	ifUnchangedSince string
+	normalizeNames   bool
 }
 
 func UploadCmd() *cobra.Command {
@@ -19,24 +26,80 @@ func UploadCmd() *cobra.Command {
 		Use:   "upload <file1> [<fileN>]...",
 		Short: "Upload variables defined in a .env file",
 		Long: "Upload variables defined in one or more .env files. The files " +
-			"should have one NAME=VALUE per line.",
+			"should have one NAME=VALUE per line. JSON, YAML, TOML and " +
+			".properties files are also supported, and nested keys are flattened " +
+			"into names such as DATABASE_HOST. Names with characters that names " +
+			"can't contain, such as dashes, are all reported, unless " +
+			"--normalize-names replaces those characters with underscores.",
 		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return envsec.ValidateFormat(flags.format)
+			if _, err := flags.unchangedSince(); err != nil {
+				return err
+			}
+			if flags.ifUnchangedSince != "" {
+				if err := ensureVersionedStore("if-unchanged-since"); err != nil {
+					return err
+				}
+			}
+			return envsec.ValidateUploadFormat(flags.format)
 		},
 		RunE: func(cmd *cobra.Command, paths []string) error {
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
 				return err
 			}
+			cmdCfg.envsec.Transactional = flags.transactional
 
-			return cmdCfg.envsec.Upload(cmd.Context(), paths, flags.format)
+			unchangedSince, _ := flags.unchangedSince()
+			return cmdCfg.envsec.Upload(cmd.Context(), paths, envsec.UploadOptions{
+				Format:           flags.format,
+				Separator:        flags.separator,
+				IfUnchangedSince: unchangedSince,
+				NormalizeNames:   flags.normalizeNames,
+			})
 		},
 	}
 
 	command.Flags().StringVarP(
-		&flags.format, "format", "f", "", "File format: dotenv or json")
+		&flags.format,
+		"format",
+		"f",
+		"",
+		"file format, one of: dotenv, json, yaml, toml, properties. "+
+			"Detected from the file if not set",
+	)
+	command.Flags().StringVar(
+		&flags.separator,
+		"separator",
+		"_",
+		"separator used to join the keys of nested values",
+	)
+	command.Flags().StringVar(
+		&flags.ifUnchangedSince,
+		"if-unchanged-since",
+		"",
+		"fail without writing if any uploaded variable was changed remotely after "+
+			"this RFC 3339 time, e.g. 2024-05-01T12:00:00Z. Only supported by the AWS Parameter Store",
+	)
+	command.Flags().BoolVar(
+		&flags.normalizeNames,
+		"normalize-names",
+		false,
+		"replace the characters that variable names can't contain, such as dashes, with underscores",
+	)
+	flags.registerTransaction(command)
 	flags.register(command)
 
 	return command
 }
+
+func (f *uploadCmdFlags) unchangedSince() (time.Time, error) {
+	if f.ifUnchangedSince == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, f.ifUnchangedSince)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid --if-unchanged-since")
+	}
+	return t, nil
+}
@@ -0,0 +1,127 @@
+package envsec
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Encoding is how a value is represented where only text is allowed, such as
+// environment variables, JSON or stores that only hold text.
+type Encoding string
+
+const (
+	// EncodingRaw uses the bytes of the value as they are.
+	EncodingRaw Encoding = "raw"
+	// EncodingBase64 uses the standard base64 encoding of the value.
+	EncodingBase64 Encoding = "base64"
+)
+
+// Encodings are all the supported encodings.
+var Encodings = []Encoding{EncodingRaw, EncodingBase64}
+
+// ParseEncoding returns the encoding with the given name. Empty defaults to
+// EncodingBase64, which is safe everywhere.
+func ParseEncoding(name string) (Encoding, error) {
+	switch Encoding(strings.ToLower(name)) {
+	case "", EncodingBase64:
+		return EncodingBase64, nil
+	case EncodingRaw:
+		return EncodingRaw, nil
+	}
+	return "", errors.Errorf("unknown encoding %q. Must be one of: %v", name, Encodings)
+}
+
+// IsBinary reports whether value is binary data rather than text: it isn't
+// valid UTF-8 or it contains NUL bytes, which environment variables can't
+// hold.
+func IsBinary(value string) bool {
+	return !utf8.ValidString(value) || strings.ContainsRune(value, 0)
+}
+
+// ValueEncoding returns the encoding that value needs to be represented as
+// text: EncodingBase64 for binary values and EncodingRaw for text.
+func ValueEncoding(value string) Encoding {
+	if IsBinary(value) {
+		return EncodingBase64
+	}
+	return EncodingRaw
+}
+
+// EncodeBinaryValues returns envVars with their binary values represented
+// with encoding. Text values are never changed.
+func EncodeBinaryValues(envVars []EnvVar, encoding Encoding) []EnvVar {
+	if encoding == EncodingRaw {
+		return envVars
+	}
+	result := make([]EnvVar, 0, len(envVars))
+	for _, envVar := range envVars {
+		if IsBinary(envVar.Value) {
+			envVar.Value = base64.StdEncoding.EncodeToString([]byte(envVar.Value))
+		}
+		result = append(result, envVar)
+	}
+	return result
+}
+
+// binaryNames returns the sorted names of the variables with binary values.
+func binaryNames(envVars []EnvVar) []string {
+	names := []string{}
+	for _, envVar := range envVars {
+		if IsBinary(envVar.Value) {
+			names = append(names, envVar.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// encodeValues splits values into a JSON-safe map, in which binary values
+// are base64 encoded, and the encodings of those binary values.
+func encodeValues(values map[string]string) (map[string]string, map[string]Encoding) {
+	encoded := map[string]string{}
+	var encodings map[string]Encoding
+	for name, value := range values {
+		if IsBinary(value) {
+			if encodings == nil {
+				encodings = map[string]Encoding{}
+			}
+			encodings[name] = EncodingBase64
+			value = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		encoded[name] = value
+	}
+	return encoded, encodings
+}
+
+// decodeValues reverses encodeValues.
+func decodeValues(encoded map[string]string, encodings map[string]Encoding) (map[string]string, error) {
+	values := map[string]string{}
+	for name, value := range encoded {
+		switch encodings[name] {
+		case "", EncodingRaw:
+		case EncodingBase64:
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid base64 value for %s", name)
+			}
+			value = string(decoded)
+		default:
+			return nil, errors.Errorf("unknown encoding %q for %s", encodings[name], name)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// printableValue returns value as shown by ls, where binary values are
+// base64 encoded with a base64: prefix.
+func printableValue(value string) string {
+	if IsBinary(value) {
+		return string(EncodingBase64) + ":" + base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return value
+}
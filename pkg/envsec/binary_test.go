@@ -0,0 +1,60 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// binaryValue is not valid UTF-8 and contains a NUL byte.
+const binaryValue = "\x30\x82\x00\xff\xfe"
+
+func TestEncodeBinaryValues(t *testing.T) {
+	envVars := []EnvVar{{"TEXT", "héllo"}, {"CERT", binaryValue}}
+	encoded := EncodeBinaryValues(envVars, EncodingBase64)
+	expected := []EnvVar{{"TEXT", "héllo"}, {"CERT", "MIIA//4="}}
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("Expected %v, but got %v", expected, encoded)
+	}
+	if raw := EncodeBinaryValues(envVars, EncodingRaw); !reflect.DeepEqual(raw, envVars) {
+		t.Errorf("Expected raw values to be unchanged, but got %v", raw)
+	}
+
+	if _, err := (&Envsec{}).ExecEnv(context.Background(), envVars, ExecOptions{
+		Binary: EncodingRaw,
+	}); err == nil || !strings.Contains(err.Error(), "CERT") {
+		t.Errorf("Expected raw values with NUL bytes to be rejected, but got %v", err)
+	}
+}
+
+func TestSnapshotBinaryValues(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:        store,
+		EnvID:        EnvID{ProjectID: "proj", EnvName: "dev"},
+		IdentityFile: filepath.Join(dir, "identity.txt"),
+		Stderr:       io.Discard,
+		WorkingDir:   dir,
+		AssumeYes:    true,
+	}
+	if err := e.SetMap(ctx, map[string]string{"CERT": binaryValue}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.CreateSnapshot(ctx, "cert", SnapshotStorageLocal); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set(ctx, "CERT", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RestoreSnapshot(ctx, "cert", false); err != nil {
+		t.Fatal(err)
+	}
+	if value := store.env(e.EnvID)["CERT"]; value != binaryValue {
+		t.Errorf("Expected the bytes of CERT to be restored, but got %q", value)
+	}
+}
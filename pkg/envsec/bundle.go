@@ -0,0 +1,315 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/seal"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// BundleFormatVersion is the version of the bundle format written by Backup.
+//
+// A bundle is a text file. Its first line is "envsec-bundle v<version>", and
+// the rest is an ASCII armored age file that decrypts to a JSON Bundle:
+//
+//	{
+//	  "version": 1,
+//	  "created_at": "2024-10-18T16:16:00Z",
+//	  "project_id": "proj_...",
+//	  "org_id": "org_...",
+//	  "environments": {
+//	    "dev": {
+//	      "config": {"extends": "base", "protected": true},
+//	      "variables": {"NAME": "value", "CERT": "MIIK..."},
+//	      "encodings": {"CERT": "base64"}
+//	    }
+//	  }
+//	}
+//
+// Binary values are base64 encoded, and listed in encodings. Readers reject
+// bundles with a newer version than they know about.
+const BundleFormatVersion = 1
+
+const bundleHeader = "envsec-bundle v"
+
+// Bundle holds every environment of a project.
+type Bundle struct {
+	Version      int                          `json:"version"`
+	CreatedAt    time.Time                    `json:"created_at"`
+	ProjectID    string                       `json:"project_id"`
+	OrgID        string                       `json:"org_id,omitempty"`
+	Environments map[string]BundleEnvironment `json:"environments"`
+}
+
+// BundleEnvironment is an environment along with its configuration in the
+// project.
+type BundleEnvironment struct {
+	Config    EnvironmentConfig `json:"config"`
+	Variables map[string]string `json:"variables"`
+	// Encodings are the encodings of binary variables in a bundle file. They
+	// are decoded when the bundle is read, so Variables always holds the
+	// actual values.
+	Encodings map[string]Encoding `json:"encodings,omitempty"`
+}
+
+// RestoreBundleOptions configures RestoreBundle.
+type RestoreBundleOptions struct {
+	// Identities decrypt the bundle. Defaults to the identity in IdentityFile.
+	Identities []age.Identity
+	// DryRun only prints the changes.
+	DryRun bool
+	// Prune deletes the variables that are not in the bundle.
+	Prune bool
+}
+
+// Backup writes every environment of the project to an encrypted bundle at
+// path. The bundle is encrypted to recipients, which are required: unlike
+// snapshots, backups are meant to outlive this machine, so they aren't
+// encrypted to the identity in IdentityFile by default.
+func (e *Envsec) Backup(ctx context.Context, path string, recipients ...age.Recipient) error {
+	if len(recipients) == 0 {
+		return errors.New(
+			"a backup must be encrypted to at least one recipient or a passphrase, " +
+				"so that it can be restored if this machine is lost")
+	}
+	bundle, err := e.readBundle(ctx)
+	if err != nil {
+		return err
+	}
+	for name, env := range bundle.Environments {
+		env.Variables, env.Encodings = encodeValues(env.Variables)
+		bundle.Environments[name] = env
+	}
+	plaintext, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ciphertext, err := seal.Encrypt(plaintext, recipients...)
+	if err != nil {
+		return err
+	}
+	data := append([]byte(fmt.Sprintf("%s%d\n", bundleHeader, BundleFormatVersion)), ciphertext...)
+	if err := writeFileAtomic(path, data, defaultFileMode); err != nil {
+		return err
+	}
+
+	names := lo.Keys(bundle.Environments)
+	sort.Strings(names)
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Backed up %s %s to %q\n",
+		tux.Plural(names, "environment", "environments"),
+		strings.Join(names, ", "),
+		path,
+	)
+}
+
+func (e *Envsec) readBundle(ctx context.Context) (*Bundle, error) {
+	environments, err := e.Environments()
+	if err != nil {
+		return nil, err
+	}
+	// Also back up environments that have variables in the store but aren't
+	// listed in the project.
+	names, err := e.AllEnvironmentNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		Version:      BundleFormatVersion,
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+		ProjectID:    e.EnvID.ProjectID,
+		OrgID:        e.EnvID.OrgID,
+		Environments: map[string]BundleEnvironment{},
+	}
+	for _, name := range names {
+		cfg := environments[name]
+		envID := e.EnvID
+		envID.EnvName = name
+		envVars, err := e.Store.List(ctx, envID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list environment %s", name)
+		}
+		variables := map[string]string{}
+		for _, envVar := range envVars {
+			if !isReservedName(envVar.Name) {
+				variables[envVar.Name] = envVar.Value
+			}
+		}
+		bundle.Environments[name] = BundleEnvironment{Config: cfg, Variables: variables}
+	}
+	return bundle, nil
+}
+
+// ReadBundleFile decrypts the bundle at path with identities.
+func ReadBundleFile(path string, identities ...age.Identity) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	header, ciphertext, _ := bytes.Cut(data, []byte("\n"))
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(string(header)), bundleHeader))
+	if !strings.HasPrefix(string(header), bundleHeader) || err != nil {
+		return nil, errors.Errorf("%s is not an envsec bundle", path)
+	}
+	if version > BundleFormatVersion {
+		return nil, errors.Errorf(
+			"%s has bundle format v%d, but this version of envsec only reads up to v%d. "+
+				"Upgrade envsec to restore it",
+			path,
+			version,
+			BundleFormatVersion,
+		)
+	}
+	plaintext, err := seal.Decrypt(ciphertext, identities...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %s", path)
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(plaintext, bundle); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	for name, env := range bundle.Environments {
+		env.Variables, err = decodeValues(env.Variables, env.Encodings)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse environment %s of %s", name, path)
+		}
+		env.Encodings = nil
+		bundle.Environments[name] = env
+	}
+	return bundle, nil
+}
+
+// RestoreBundle writes the environments in the bundle at path to the
+// project, which doesn't have to be the one it was backed up from. It prints
+// the changes to each environment and asks for confirmation before making
+// them. Environments that don't exist in the project are created with their
+// configuration from the bundle. Existing environments keep theirs.
+func (e *Envsec) RestoreBundle(ctx context.Context, path string, opts RestoreBundleOptions) error {
+	identities := opts.Identities
+	if len(identities) == 0 {
+		identity, err := e.identity()
+		if err != nil {
+			return err
+		}
+		identities = []age.Identity{identity}
+	}
+	bundle, err := ReadBundleFile(path, identities...)
+	if err != nil {
+		return err
+	}
+	existing, err := e.Environments()
+	if err != nil {
+		return err
+	}
+
+	names := lo.Keys(bundle.Environments)
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ValidateEnvironmentName(name); err != nil {
+			return err
+		}
+	}
+	err = tux.WriteHeader(e.Stderr,
+		"Restoring backup of project %s, created at %s, to project: %s\n",
+		bundle.ProjectID,
+		bundle.CreatedAt.Local().Format(time.DateTime),
+		e.EnvID.ProjectID,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	changes := map[string][]Change{}
+	total := 0
+	created := map[string]EnvironmentConfig{}
+	for _, name := range names {
+		envID := e.EnvID
+		envID.EnvName = name
+		envVars, err := e.Store.List(ctx, envID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list environment %s", name)
+		}
+		current := map[string]string{}
+		for _, envVar := range envVars {
+			if !isReservedName(envVar.Name) {
+				current[envVar.Name] = envVar.Value
+			}
+		}
+		changes[name] = DiffEnvVars(current, bundle.Environments[name].Variables, opts.Prune)
+		total += len(changes[name])
+
+		heading := "Environment " + name
+		if _, ok := existing[name]; !ok {
+			if err := e.ensureSupportedEnvironment(name); err != nil {
+				return err
+			}
+			created[name] = bundle.Environments[name].Config
+			heading += " (new)"
+		}
+		if _, err := fmt.Fprintln(e.Stderr, heading+":"); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := PrintChanges(e.Stderr, changes[name]); err != nil {
+			return err
+		}
+	}
+	if opts.DryRun || (total == 0 && len(created) == 0) {
+		return nil
+	}
+
+	ok, err := e.askYesNo(fmt.Sprintf("Restore %d %s to %d %s?",
+		total,
+		tux.Plural(make([]any, total), "change", "changes"),
+		len(names),
+		tux.Plural(names, "environment", "environments"),
+	))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("aborted")
+	}
+	for _, name := range names {
+		if len(changes[name]) > 0 {
+			if err := e.ensureWritable(name, "restore a backup"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range names {
+		target := *e
+		target.EnvID.EnvName = name
+		if err := target.applyChanges(ctx, bundle.Environments[name].Variables, changes[name]); err != nil {
+			return errors.Wrapf(err, "failed to restore environment %s", name)
+		}
+	}
+	if len(created) > 0 {
+		err := e.updateEnvironments(func(environments map[string]EnvironmentConfig) error {
+			for name, cfg := range created {
+				environments[name] = cfg
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Restored %s %s from %q\n",
+		tux.Plural(names, "environment", "environments"),
+		strings.Join(names, ", "),
+		path,
+	)
+}
@@ -0,0 +1,116 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestBackupAndRestoreBundle(t *testing.T) {
+	ctx := context.Background()
+	source := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := source.writeConfigForTest(map[string]EnvironmentConfig{
+		"dev":     {},
+		"staging": {Extends: "dev", Protected: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetMap(ctx, map[string]string{"A": "1", "B": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	source.EnvID.EnvName = "staging"
+	source.AssumeYes = true
+	if err := source.Set(ctx, "B", "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "project.bundle")
+	if err := source.Backup(ctx, path, identity.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newMemStore()
+	target := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "other", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := target.writeConfigForTest(map[string]EnvironmentConfig{"dev": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Set(ctx, "C", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := RestoreBundleOptions{Identities: []age.Identity{identity}, DryRun: true, Prune: true}
+	if err := target.RestoreBundle(ctx, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.env(EnvID{ProjectID: "other", EnvName: "dev"}); len(got) != 1 {
+		t.Errorf("Expected a dry run to change nothing, but got %v", got)
+	}
+
+	opts.DryRun = false
+	target.Stdin = strings.NewReader("y\n")
+	if err := target.RestoreBundle(ctx, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": "2"}
+	if got := store.env(EnvID{ProjectID: "other", EnvName: "dev"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected dev to be %v, but got %v", want, got)
+	}
+	want = map[string]string{"B": "staging"}
+	if got := store.env(EnvID{ProjectID: "other", EnvName: "staging"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected staging to be %v, but got %v", want, got)
+	}
+	environments, err := target.Environments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := environments["staging"]; cfg.Extends != "dev" || !cfg.Protected {
+		t.Errorf("Expected staging to be created with its config, but got %+v", cfg)
+	}
+}
+
+func TestReadBundleFileRejectsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.bundle")
+	if err := os.WriteFile(path, []byte("envsec-bundle v99\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadBundleFile(path)
+	if err == nil || !strings.Contains(err.Error(), "v99") {
+		t.Errorf("Expected an error about the format version, but got %v", err)
+	}
+}
+
+func TestBackupRequiresRecipients(t *testing.T) {
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	path := filepath.Join(t.TempDir(), "project.bundle")
+	if err := e.Backup(context.Background(), path); err == nil {
+		t.Error("Expected a backup without recipients to fail")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no bundle to be written, but got %v", err)
+	}
+}
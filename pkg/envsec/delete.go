@@ -8,7 +8,11 @@ import (
 )
 
 func (e *Envsec) DeleteAll(ctx context.Context, envNames ...string) error {
-	if err := e.Store.DeleteAll(ctx, e.EnvID, envNames); err != nil {
+	err := e.ensureWritable(e.EnvID.EnvName, "delete "+strings.Join(envNames, ", "))
+	if err != nil {
+		return err
+	}
+	if err := e.deleteAll(ctx, envNames); err != nil {
 		return err
 	}
 	return tux.WriteHeader(e.Stderr,
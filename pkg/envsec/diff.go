@@ -0,0 +1,133 @@
+package envsec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// ChangeKind describes how a variable differs between two sets of variables.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "+"
+	ChangeUpdated ChangeKind = "~"
+	ChangeRemoved ChangeKind = "-"
+)
+
+// Change is a variable that is added, updated or removed when replacing the
+// current variables with a target set.
+type Change struct {
+	Name string
+	Kind ChangeKind
+}
+
+// DiffEnvVars returns the changes needed to turn current into target, sorted
+// by name. Variables missing from target are only removed if prune is set.
+func DiffEnvVars(current, target map[string]string, prune bool) []Change {
+	changes := []Change{}
+	for name, value := range target {
+		if old, ok := current[name]; !ok {
+			changes = append(changes, Change{Name: name, Kind: ChangeAdded})
+		} else if old != value {
+			changes = append(changes, Change{Name: name, Kind: ChangeUpdated})
+		}
+	}
+	if prune {
+		for name := range current {
+			if _, ok := target[name]; !ok {
+				changes = append(changes, Change{Name: name, Kind: ChangeRemoved})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// PrintChanges prints one line per change. Values are never printed.
+func PrintChanges(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes")
+		return errors.WithStack(err)
+	}
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(w, "  %s %s\n", change.Kind, change.Name); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// applyChanges writes the added and updated variables of changes with their
+// values in target, and deletes the removed ones. The environment must
+// already have been checked with ensureWritable.
+func (e *Envsec) applyChanges(ctx context.Context, target map[string]string, changes []Change) error {
+	toSet := map[string]string{}
+	toDelete := []string{}
+	for _, change := range changes {
+		if change.Kind == ChangeRemoved {
+			toDelete = append(toDelete, change.Name)
+		} else {
+			toSet[change.Name] = target[change.Name]
+		}
+	}
+	if len(toSet) > 0 {
+		if err := e.setAll(ctx, toSet); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(toDelete) > 0 {
+		if err := e.deleteAll(ctx, toDelete); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// askYesNo asks a yes/no question on Stderr and reads the answer from Stdin.
+// It returns true without asking if AssumeYes is set, and fails if there is
+// no Stdin to read from.
+func (e *Envsec) askYesNo(question string) (bool, error) {
+	if e.AssumeYes {
+		return true, nil
+	}
+	if e.Stdin == nil {
+		return false, errors.New(
+			"confirmation is required. Run the command interactively or pass --yes")
+	}
+	if err := tux.WriteHeader(e.Stderr, "%s [y/N]: ", question); err != nil {
+		return false, errors.WithStack(err)
+	}
+	answer, err := readLine(e.Stdin)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read confirmation")
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// confirmChanges asks the user to confirm action on the environment. Changes
+// to protected environments require typing the environment name, like any
+// other write to them, and frozen environments can't be changed at all.
+func (e *Envsec) confirmChanges(action string) error {
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	if cfg := environments[e.EnvID.EnvName]; cfg.Protected || cfg.Frozen {
+		return e.ensureWritable(e.EnvID.EnvName, action)
+	}
+	ok, err := e.askYesNo(fmt.Sprintf("Do you want to %s?", action))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("aborted")
+	}
+	return nil
+}
@@ -10,13 +10,37 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.jetify.com/envsec/internal/tux"
 )
 
+type DownloadOptions struct {
+	// Format of the file, see FormatNames. If empty, the format is inferred from
+	// the extension of path and defaults to dotenv.
+	Format string
+	// Merge updates only the variables managed remotely in an existing file,
+	// preserving its comments, blank lines, key order and local-only keys.
+	// Only dotenv and json files can be merged.
+	Merge bool
+	// FileMode is the permission of the written file. Defaults to 0600, or
+	// when merging to the permission of the existing file without the bits
+	// that make it looser than 0600.
+	FileMode os.FileMode
+	// Binary is how binary values are written. Defaults to EncodingBase64.
+	// EncodingRaw writes their bytes as they are, which only formats without
+	// a text encoding of their own can hold.
+	Binary Encoding
+}
+
+// textOnlyFormats are the formats that can't hold binary values.
+var textOnlyFormats = []string{"json", "yaml", "toml", "tfvars"}
+
 // Download downloads the environment variables for the environment specified.
-// If format is empty, we default to dotenv format unless path ends in .json
-func (e *Envsec) Download(ctx context.Context, path, format string) error {
-	if err := ValidateFormat(format); err != nil {
+// If path is "-" the variables are written to stdout. Otherwise the file is
+// written atomically, so a failed download never leaves a partially written
+// file behind.
+func (e *Envsec) Download(ctx context.Context, path string, opts DownloadOptions) error {
+	if err := ValidateFormat(opts.Format); err != nil {
 		return err
 	}
 
@@ -32,37 +56,73 @@ func (e *Envsec) Download(ctx context.Context, path, format string) error {
 		)
 		return errors.WithStack(err)
 	}
+	envVars = sortedEnvVars(envVars)
 
-	if !filepath.IsAbs(path) {
+	toStdout := path == "-"
+	if !toStdout && !filepath.IsAbs(path) {
 		path = filepath.Join(e.WorkingDir, path)
 	}
 
-	envVarMap := map[string]string{}
-	for _, envVar := range envVars {
-		envVarMap[envVar.Name] = envVar.Value
+	format := formatForPath(opts.Format, path)
+	if opts.Binary == EncodingRaw && lo.Contains(textOnlyFormats, format.Name) {
+		if binary := binaryNames(envVars); len(binary) > 0 {
+			return errors.Errorf(
+				"%s can't be written raw to a %s file. Use --binary base64 instead",
+				strings.Join(binary, ", "),
+				format.Name,
+			)
+		}
+	}
+	envVars = EncodeBinaryValues(envVars, opts.Binary)
+	if opts.Merge && (toStdout || (format.Name != "dotenv" && format.Name != "json")) {
+		return errors.Errorf(
+			"--merge is only supported when downloading to a dotenv or json file")
 	}
 
-	if format == "" && filepath.Ext(path) == ".json" {
-		format = "json"
+	var existing []byte
+	fileMode := opts.FileMode
+	if opts.Merge {
+		existing, err = os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.WithStack(err)
+		}
+		// Files written by earlier versions may be readable by others, which
+		// merging secrets into them mustn't keep.
+		if info, err := os.Stat(path); err == nil && fileMode == 0 {
+			fileMode = info.Mode().Perm() & defaultFileMode
+		}
 	}
 
 	var contents []byte
-	if format == "json" {
-		contents, err = encodeToJSON(envVarMap)
-	} else {
-		contents, err = encodeToDotEnv(envVarMap)
+	switch {
+	case existing != nil && format.Name == "json":
+		contents, err = mergeJSON(existing, envVarsToMap(envVars))
+	case existing != nil:
+		contents, err = mergeDotEnv(existing, envVarsToMap(envVars))
+	default:
+		contents, err = format.Encode(envVars)
 	}
 
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	err = os.WriteFile(path, contents, 0o644)
+	if toStdout {
+		_, err = e.stdout().Write(contents)
+		return errors.WithStack(err)
+	}
+
+	err = writeFileAtomic(path, contents, fileMode)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	verb := "Downloaded"
+	if existing != nil {
+		verb = "Merged"
+	}
 	err = tux.WriteHeader(e.Stderr,
-		"[DONE] Downloaded environment variables to %q for environment: %s\n",
+		"[DONE] %s environment variables to %q for environment: %s\n",
+		verb,
 		path,
 		strings.ToLower(e.EnvID.EnvName),
 	)
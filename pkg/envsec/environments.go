@@ -0,0 +1,396 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/git"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// DefaultEnvironments are the environments of projects that don't list their
+// own in environmentsName.
+var DefaultEnvironments = []string{"dev", "preview", "prod"}
+
+// environmentsName is the file in the .jetify directory that lists the
+// environments of the project. Unlike the rest of the directory it is checked
+// in, so that every checkout of the project shares the same environments.
+const environmentsName = "environments.json"
+
+type environmentsConfig struct {
+	Environments map[string]EnvironmentConfig `json:"environments"`
+}
+
+// EnvironmentLimitedStore is implemented by stores that only hold variables
+// for some environment names.
+type EnvironmentLimitedStore interface {
+	// SupportedEnvironments returns the environment names the store accepts.
+	SupportedEnvironments() []string
+}
+
+// EnvironmentLister is implemented by stores that can list the environments
+// that have variables, including environments the project doesn't list.
+type EnvironmentLister interface {
+	// ListEnvironments returns the names of the environments of the project
+	// of envID that have variables.
+	ListEnvironments(ctx context.Context, envID EnvID) ([]string, error)
+}
+
+var environmentNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// ValidateEnvironmentName checks that name can be used for a new environment.
+// Names keep their case, since stores such as Parameter Store are case
+// sensitive.
+func ValidateEnvironmentName(name string) error {
+	if !environmentNameRegex.MatchString(name) {
+		return errors.Errorf(
+			"environment name %q must match the regular expression: %s",
+			name,
+			environmentNameRegex,
+		)
+	}
+	if name == LocalLayer {
+		return errors.Errorf("environment name %q is reserved for local overrides", name)
+	}
+	return nil
+}
+
+// ensureSupportedEnvironment checks that Store can hold the variables of the
+// environment name.
+func (e *Envsec) ensureSupportedEnvironment(name string) error {
+	limited, ok := e.Store.(EnvironmentLimitedStore)
+	if !ok {
+		return nil
+	}
+	supported := limited.SupportedEnvironments()
+	if lo.Contains(supported, name) {
+		return nil
+	}
+	return errors.Errorf(
+		"the store of this project only supports the environments %s, not %q",
+		strings.Join(supported, ", "),
+		name,
+	)
+}
+
+// EnvironmentNames returns the sorted names of the environments of the
+// project.
+func (e *Envsec) EnvironmentNames() ([]string, error) {
+	environments, err := e.Environments()
+	if err != nil {
+		return nil, err
+	}
+	names := lo.Keys(environments)
+	sort.Strings(names)
+	return names, nil
+}
+
+// AllEnvironmentNames returns the sorted names of the environments of the
+// project, along with the environments that have variables in Store but
+// aren't listed in the project, if Store can list them.
+func (e *Envsec) AllEnvironmentNames(ctx context.Context) ([]string, error) {
+	names, err := e.EnvironmentNames()
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := e.Store.(EnvironmentLister)
+	if !ok {
+		return names, nil
+	}
+	listed, err := lister.ListEnvironments(ctx, e.EnvID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names = lo.Uniq(append(names, listed...))
+	sort.Strings(names)
+	return names, nil
+}
+
+// ValidateEnvironments checks that Store supports all names. Names that aren't
+// environments of the project are allowed, since their variables may have
+// been set before the project listed its environments, but they are reported
+// in a warning.
+func (e *Envsec) ValidateEnvironments(names ...string) error {
+	known, err := e.EnvironmentNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if lo.Contains(known, name) {
+			continue
+		}
+		if err := e.ensureSupportedEnvironment(name); err != nil {
+			return err
+		}
+		err := tux.WriteHeader(e.Stderr,
+			"[WARNING] Environment %s is not one of the environments of this project: %s. "+
+				"Use `envsec env create %s` to add it\n",
+			name,
+			strings.Join(known, ", "),
+			name,
+		)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// ensureNewEnvironment checks that name isn't an environment of the project,
+// including one that only differs in case, which would be confusing.
+func ensureNewEnvironment(environments map[string]EnvironmentConfig, name string) error {
+	for existing := range environments {
+		if existing == name {
+			return errors.Errorf("environment %q already exists", name)
+		}
+		if strings.EqualFold(existing, name) {
+			return errors.Errorf("environment %q already exists as %q", name, existing)
+		}
+	}
+	return nil
+}
+
+// CreateEnvironment adds an environment to the project.
+func (e *Envsec) CreateEnvironment(name string, cfg EnvironmentConfig) error {
+	if err := ValidateEnvironmentName(name); err != nil {
+		return err
+	}
+	if err := e.ensureSupportedEnvironment(name); err != nil {
+		return err
+	}
+	err := e.updateEnvironments(func(environments map[string]EnvironmentConfig) error {
+		if err := ensureNewEnvironment(environments, name); err != nil {
+			return err
+		}
+		if _, ok := environments[cfg.Extends]; cfg.Extends != "" && !ok {
+			return errors.Errorf("environment %q extends unknown environment %q", name, cfg.Extends)
+		}
+		environments[name] = cfg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr, "[DONE] Created environment: %s\n", name)
+}
+
+// RemoveEnvironment removes an environment from the project. An environment
+// that still has variables is only removed if deleteVars is set, in which
+// case its variables are deleted first.
+func (e *Envsec) RemoveEnvironment(ctx context.Context, name string, deleteVars bool) error {
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	if _, ok := environments[name]; !ok {
+		return errors.Errorf("environment %q does not exist", name)
+	}
+	if len(environments) == 1 {
+		return errors.New("a project must have at least one environment")
+	}
+	if err := ensureNotExtended(environments, name); err != nil {
+		return err
+	}
+	if err := e.ensureWritable(name, "remove the environment"); err != nil {
+		return err
+	}
+
+	envID := e.EnvID
+	envID.EnvName = name
+	envVars, err := e.Store.List(ctx, envID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(envVars) > 0 {
+		if !deleteVars {
+			return errors.Errorf(
+				"environment %q still has %d %s. Delete them first or use --force",
+				name,
+				len(envVars),
+				tux.Plural(envVars, "variable", "variables"),
+			)
+		}
+		names := lo.Map(envVars, func(envVar EnvVar, _ int) string { return envVar.Name })
+		if err := e.Store.DeleteAll(ctx, envID, names); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	err = e.updateEnvironments(func(environments map[string]EnvironmentConfig) error {
+		delete(environments, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr, "[DONE] Removed environment: %s\n", name)
+}
+
+// RenameEnvironment renames an environment, moving its variables to the new
+// name and updating the environments that extend it.
+func (e *Envsec) RenameEnvironment(ctx context.Context, oldName, newName string) error {
+	if err := ValidateEnvironmentName(newName); err != nil {
+		return err
+	}
+	if err := e.ensureSupportedEnvironment(newName); err != nil {
+		return err
+	}
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	if _, ok := environments[oldName]; !ok {
+		return errors.Errorf("environment %q does not exist", oldName)
+	}
+	// Only changing the case of the name clashes with the environment itself.
+	others := lo.OmitByKeys(environments, []string{oldName})
+	if err := ensureNewEnvironment(others, newName); err != nil {
+		return err
+	}
+	if oldName == newName {
+		return errors.Errorf("environment %q already exists", newName)
+	}
+	if err := e.ensureWritable(oldName, "rename the environment"); err != nil {
+		return err
+	}
+
+	oldID, newID := e.EnvID, e.EnvID
+	oldID.EnvName, newID.EnvName = oldName, newName
+	envVars, err := e.Store.List(ctx, oldID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(envVars) > 0 {
+		if err := e.Store.SetAll(ctx, newID, envVarsToMap(envVars)); err != nil {
+			return errors.Wrapf(err, "failed to copy variables to environment %s", newName)
+		}
+	}
+
+	// Update the config before deleting the old variables, so that a failure
+	// leaves the variables reachable under the new name.
+	err = e.updateEnvironments(func(environments map[string]EnvironmentConfig) error {
+		environments[newName] = environments[oldName]
+		delete(environments, oldName)
+		for name, cfg := range environments {
+			if cfg.Extends == oldName {
+				cfg.Extends = newName
+				environments[name] = cfg
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(envVars) > 0 {
+		names := lo.Map(envVars, func(envVar EnvVar, _ int) string { return envVar.Name })
+		if err := e.Store.DeleteAll(ctx, oldID, names); err != nil {
+			return errors.Wrapf(err,
+				"variables were copied to environment %s, but failed to delete them from %s",
+				newName,
+				oldName,
+			)
+		}
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Renamed environment %s to %s\n", oldName, newName)
+}
+
+func ensureNotExtended(environments map[string]EnvironmentConfig, name string) error {
+	extending := []string{}
+	for other, cfg := range environments {
+		if cfg.Extends == name {
+			extending = append(extending, other)
+		}
+	}
+	if len(extending) > 0 {
+		sort.Strings(extending)
+		return errors.Errorf(
+			"environment %q is extended by %s",
+			name,
+			strings.Join(extending, ", "),
+		)
+	}
+	return nil
+}
+
+// Environments returns the environments of the project by name. They are
+// read from environmentsName, or from the project config of projects that
+// listed their environments before that file existed, and default to
+// DefaultEnvironments.
+func (e *Envsec) Environments() (map[string]EnvironmentConfig, error) {
+	data, err := os.ReadFile(e.environmentsPath())
+	if err == nil {
+		var cfg environmentsConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", e.environmentsPath())
+		}
+		return environmentsOrDefault(cfg.Environments), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+
+	cfg, err := e.ProjectConfig()
+	if errors.Is(err, errProjectNotInitialized) {
+		cfg = &projectConfig{}
+	} else if err != nil {
+		return nil, err
+	}
+	return environmentsOrDefault(cfg.Environments), nil
+}
+
+func environmentsOrDefault(environments map[string]EnvironmentConfig) map[string]EnvironmentConfig {
+	if len(environments) > 0 {
+		return environments
+	}
+	environments = map[string]EnvironmentConfig{}
+	for _, name := range DefaultEnvironments {
+		environments[name] = EnvironmentConfig{}
+	}
+	return environments
+}
+
+func (e *Envsec) environmentsPath() string {
+	return filepath.Join(e.WorkingDir, dirName, environmentsName)
+}
+
+// updateEnvironments applies update to the environments of the project and
+// saves them.
+func (e *Envsec) updateEnvironments(
+	update func(environments map[string]EnvironmentConfig) error,
+) error {
+	if _, err := e.ProjectConfig(); errors.Is(err, errProjectNotInitialized) {
+		return errors.New("project not initialized. You must run `envsec init` in this directory")
+	} else if err != nil {
+		return err
+	}
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	if err := update(environments); err != nil {
+		return err
+	}
+	return e.writeEnvironments(environments)
+}
+
+// writeEnvironments saves environments to environmentsName, and makes sure
+// the .gitignore of the .jetify directory doesn't keep it out of git.
+func (e *Envsec) writeEnvironments(environments map[string]EnvironmentConfig) error {
+	data, err := json.MarshalIndent(environmentsConfig{Environments: environments}, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// The file holds no secrets and is checked in.
+	if err := writeFileAtomic(e.environmentsPath(), append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return errors.WithStack(git.KeepInGitIgnore(filepath.Join(e.WorkingDir, dirName), environmentsName))
+}
@@ -0,0 +1,220 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentManagement(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.writeConfigForTest(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := e.EnvironmentNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, DefaultEnvironments) {
+		t.Errorf("Expected default environments %v, but got %v", DefaultEnvironments, names)
+	}
+
+	if err := e.CreateEnvironment("qa", EnvironmentConfig{Extends: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"qa", "bad name", "DEV", "local"} {
+		if err := e.CreateEnvironment(name, EnvironmentConfig{}); err == nil {
+			t.Errorf("Expected an error creating environment %q", name)
+		}
+	}
+	if err := e.ValidateEnvironments("dev", "qa"); err != nil {
+		t.Error(err)
+	}
+
+	store.env(EnvID{ProjectID: "proj", EnvName: "dev"})["A"] = "1"
+	if err := e.RenameEnvironment(ctx, "dev", "base"); err != nil {
+		t.Fatal(err)
+	}
+	environments, err := e.Environments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]EnvironmentConfig{
+		"base": {}, "preview": {}, "prod": {}, "qa": {Extends: "base"},
+	}
+	if !reflect.DeepEqual(environments, expected) {
+		t.Errorf("Expected %v, but got %v", expected, environments)
+	}
+	if value := store.env(EnvID{ProjectID: "proj", EnvName: "base"})["A"]; value != "1" {
+		t.Errorf("Expected variables to move to the new environment")
+	}
+
+	if err := e.RemoveEnvironment(ctx, "base", true); err == nil {
+		t.Error("Expected an error removing an extended environment")
+	}
+	if err := e.RemoveEnvironment(ctx, "qa", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RemoveEnvironment(ctx, "base", false); err == nil {
+		t.Error("Expected an error removing an environment with variables")
+	}
+	if err := e.RemoveEnvironment(ctx, "base", true); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := e.EnvironmentNames(); !reflect.DeepEqual(names, []string{"preview", "prod"}) {
+		t.Errorf("Expected [preview prod], but got %v", names)
+	}
+}
+
+// limitedStore is a memStore that only supports the default environments.
+type limitedStore struct {
+	*memStore
+}
+
+func (limitedStore) SupportedEnvironments() []string {
+	return DefaultEnvironments
+}
+
+func TestUnsupportedEnvironments(t *testing.T) {
+	e := &Envsec{
+		Store:      limitedStore{newMemStore()},
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.writeConfigForTest(map[string]EnvironmentConfig{"dev": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CreateEnvironment("staging", EnvironmentConfig{}); err == nil {
+		t.Error("Expected an error creating an environment the store doesn't support")
+	}
+	if err := e.CreateEnvironment("prod", EnvironmentConfig{}); err != nil {
+		t.Error(err)
+	}
+	if err := e.RenameEnvironment(context.Background(), "dev", "staging"); err == nil {
+		t.Error("Expected an error renaming to an environment the store doesn't support")
+	}
+}
+
+func TestUnknownEnvironmentsWarn(t *testing.T) {
+	var stderr bytes.Buffer
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     &stderr,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.writeConfigForTest(nil); err != nil {
+		t.Fatal(err)
+	}
+	// Parameter Store paths are case sensitive, so names keep their case.
+	if err := e.ValidateEnvironments("Staging"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "Environment Staging is not one of the environments") {
+		t.Errorf("Expected a warning about the unknown environment, but got %q", stderr.String())
+	}
+
+	e.Store = limitedStore{newMemStore()}
+	if err := e.ValidateEnvironments("staging"); err == nil {
+		t.Error("Expected an error for an environment the store doesn't support")
+	}
+}
+
+func TestEnvironmentsAreCheckedIn(t *testing.T) {
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	// Projects listed their environments in the project config before
+	// environments.json existed.
+	legacy := map[string]EnvironmentConfig{"dev": {}, "ci": {Protected: true}}
+	dir := filepath.Join(e.WorkingDir, dirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.writeConfig(&projectConfig{Environments: legacy}); err != nil {
+		t.Fatal(err)
+	}
+	if environments, _ := e.Environments(); !reflect.DeepEqual(environments, legacy) {
+		t.Errorf("Expected the legacy environments %v, but got %v", legacy, environments)
+	}
+
+	if err := e.CreateEnvironment("QA", EnvironmentConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CreateEnvironment("qa", EnvironmentConfig{}); err == nil {
+		t.Error("Expected an error creating an environment that only differs in case")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, environmentsName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"QA"`) || !strings.Contains(string(data), `"ci"`) {
+		t.Errorf("Expected %s to list all environments, but got %s", environmentsName, data)
+	}
+	gitIgnore, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gitIgnore) != "*\n!"+environmentsName+"\n" {
+		t.Errorf("Expected .gitignore to keep %s, but got %q", environmentsName, gitIgnore)
+	}
+}
+
+// listingStore is a memStore that lists the environments with variables.
+type listingStore struct {
+	*memStore
+}
+
+func (s listingStore) ListEnvironments(ctx context.Context, envID EnvID) ([]string, error) {
+	names := []string{}
+	for id, vars := range s.envs {
+		if id.ProjectID == envID.ProjectID && len(vars) > 0 {
+			names = append(names, id.EnvName)
+		}
+	}
+	return names, nil
+}
+
+func TestAllEnvironmentNames(t *testing.T) {
+	store := newMemStore()
+	e := &Envsec{
+		Store:      listingStore{store},
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.writeConfigForTest(map[string]EnvironmentConfig{"dev": {}, "prod": {}}); err != nil {
+		t.Fatal(err)
+	}
+	store.env(EnvID{ProjectID: "proj", EnvName: "dev"})["A"] = "1"
+	store.env(EnvID{ProjectID: "proj", EnvName: "Staging"})["A"] = "1"
+	store.env(EnvID{ProjectID: "other", EnvName: "qa"})["A"] = "1"
+
+	names, err := e.AllEnvironmentNames(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Staging", "dev", "prod"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, but got %v", expected, names)
+	}
+}
@@ -3,18 +3,40 @@ package envsec
 import (
 	"context"
 	"io"
+	"os"
 
 	"go.jetify.com/pkg/auth/session"
 )
 
 type Envsec struct {
-	APIHost    string
-	Auth       AuthConfig
-	EnvID      EnvID
-	IsDev      bool
-	Stderr     io.Writer
-	Store      Store
-	WorkingDir string
+	APIHost string
+	// AssumeYes skips the confirmation of changes to protected environments.
+	AssumeYes bool
+	Auth      AuthConfig
+	EnvID     EnvID
+	// IdentityFile is the path of the age identity that encrypts snapshots.
+	// Defaults to DefaultIdentityFile.
+	IdentityFile string
+	IsDev        bool
+	// Layers are the names of the environments that EnvID's environment
+	// extends, from lowest to highest precedence. Their variables are read
+	// along with the environment's, which overrides them. Writes only ever
+	// change EnvID's environment.
+	Layers []string
+	// LocalFile is the path of a dotenv file, relative to WorkingDir, whose
+	// variables override all layers when listing. It is never written to the
+	// store. Empty disables local overrides.
+	LocalFile string
+	Stderr    io.Writer
+	// Stdin is read to confirm changes to protected environments. If nil,
+	// such changes fail unless AssumeYes is set.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Store  Store
+	// Transactional makes SetMap and DeleteAll undo their changes if they
+	// fail partway. See RollbackError.
+	Transactional bool
+	WorkingDir    string
 }
 
 type AuthConfig struct {
@@ -28,3 +50,10 @@ type AuthConfig struct {
 func (e *Envsec) InitForUser(ctx context.Context) (*session.Token, error) {
 	return e.Store.InitForUser(ctx, e)
 }
+
+func (e *Envsec) stdout() io.Writer {
+	if e.Stdout == nil {
+		return os.Stdout
+	}
+	return e.Stdout
+}
@@ -0,0 +1,117 @@
+package envsec
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/proc"
+	"go.jetify.com/envsec/internal/redact"
+)
+
+// DefaultInheritedVars are the local variables that commands executed with
+// ExecOptions.NoInherit still receive, unless ExecOptions.Inherit is set.
+var DefaultInheritedVars = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_*", "TZ", "TMPDIR",
+}
+
+type ExecOptions struct {
+	// NoInherit starts from an empty environment instead of the local one.
+	// Only the local variables matching the Inherit glob patterns are kept.
+	NoInherit bool
+	// Inherit are the glob patterns of the local variables kept with
+	// NoInherit. Defaults to DefaultInheritedVars.
+	Inherit []string
+	// Prefix is prepended to the names of the stored variables.
+	Prefix string
+	// LocalWins keeps the local value of variables that exist both locally
+	// and remotely. By default, the remote value wins.
+	LocalWins bool
+	// Binary is how binary values are set in the environment. Defaults to
+	// EncodingBase64. With EncodingRaw, values with NUL bytes are an error,
+	// since environment variables can't hold them.
+	Binary Encoding
+}
+
+// ExecEnv returns the environment for a command executed with envVars, as a
+// list of NAME=VALUE entries with one entry per name. It starts from the
+// local environment, with envsec:// references resolved, and adds envVars as
+// configured by opts.
+func (e *Envsec) ExecEnv(
+	ctx context.Context,
+	envVars []EnvVar,
+	opts ExecOptions,
+) ([]string, error) {
+	local := os.Environ()
+	if opts.NoInherit {
+		inherit := opts.Inherit
+		if inherit == nil {
+			inherit = DefaultInheritedVars
+		}
+		local = lo.Filter(local, func(kv string, _ int) bool {
+			name, _, _ := strings.Cut(kv, "=")
+			return matchesAny(name, inherit)
+		})
+	}
+	environ, err := e.ResolveEnviron(ctx, local)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Binary == EncodingRaw {
+		withNUL := lo.Filter(envVars, func(envVar EnvVar, _ int) bool {
+			return strings.ContainsRune(envVar.Value, 0)
+		})
+		if len(withNUL) > 0 {
+			return nil, errors.Errorf(
+				"%s can't be set raw in the environment because of NUL bytes. "+
+					"Use --binary base64, or --file to write them to files instead",
+				strings.Join(lo.Map(withNUL, func(envVar EnvVar, _ int) string { return envVar.Name }), ", "),
+			)
+		}
+	}
+	envVars = EncodeBinaryValues(envVars, opts.Binary)
+
+	localNames := map[string]bool{}
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		localNames[name] = true
+	}
+	for _, envVar := range envVars {
+		name := opts.Prefix + envVar.Name
+		if opts.LocalWins && localNames[name] {
+			continue
+		}
+		environ = append(environ, name+"="+envVar.Value)
+	}
+	// Programs that read the environment with getenv see the first entry of a
+	// name, so the stored value must replace the local one rather than follow
+	// it.
+	return proc.DedupEnv(environ), nil
+}
+
+// FilterEnvVars returns the variables whose names match any of the only glob
+// patterns, or all of them if only is empty, and none of the exclude ones.
+func FilterEnvVars(envVars []EnvVar, only, exclude []string) []EnvVar {
+	return lo.Filter(envVars, func(envVar EnvVar, _ int) bool {
+		return (len(only) == 0 || matchesAny(envVar.Name, only)) &&
+			!matchesAny(envVar.Name, exclude)
+	})
+}
+
+// SecretsToRedact returns the strings that should be redacted from the output
+// of a command executed with envVars: each value along with its base64 and
+// URL encoded forms. Variables matching the exclude glob patterns and values
+// shorter than minLength are not redacted.
+func SecretsToRedact(envVars []EnvVar, exclude []string, minLength int) []string {
+	secrets := []string{}
+	for _, envVar := range envVars {
+		if matchesAny(envVar.Name, exclude) {
+			continue
+		}
+		secrets = append(secrets, redact.Variants(envVar.Value, minLength)...)
+	}
+	return secrets
+}
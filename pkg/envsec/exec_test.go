@@ -0,0 +1,65 @@
+package envsec
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChangedNames(t *testing.T) {
+	before := []EnvVar{{"A", "1"}, {"B", "2"}, {"C", "3"}}
+	after := []EnvVar{{"A", "1"}, {"B", "changed"}, {"D", "4"}}
+	expected := []string{"B", "C", "D"}
+	if changed := changedNames(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, but got %v", expected, changed)
+	}
+}
+
+func TestExecEnv(t *testing.T) {
+	t.Setenv("PATH", "/bin")
+	t.Setenv("SHARED", "local")
+	e := &Envsec{}
+	envVars := []EnvVar{{"SHARED", "remote"}, {"DB_URL", "postgres://"}, {"TOKEN", "t"}}
+
+	lookup := func(environ []string, name string) []string {
+		values := []string{}
+		for _, kv := range environ {
+			if n, v, _ := strings.Cut(kv, "="); n == name {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+
+	environ, err := e.ExecEnv(context.Background(), envVars, ExecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The remote value replaces the local one.
+	if values := lookup(environ, "SHARED"); !reflect.DeepEqual(values, []string{"remote"}) {
+		t.Errorf("Expected only the remote value, but got %v", values)
+	}
+
+	environ, err = e.ExecEnv(context.Background(), envVars, ExecOptions{LocalWins: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := lookup(environ, "SHARED"); !reflect.DeepEqual(values, []string{"local"}) {
+		t.Errorf("Expected only the local value, but got %v", values)
+	}
+
+	filtered := FilterEnvVars(envVars, []string{"DB_*", "TOKEN"}, []string{"TOKEN"})
+	environ, err = e.ExecEnv(context.Background(), filtered, ExecOptions{
+		NoInherit: true,
+		Inherit:   []string{"PATH"},
+		Prefix:    "APP_",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"PATH=/bin", "APP_DB_URL=postgres://"}
+	if !reflect.DeepEqual(environ, expected) {
+		t.Errorf("Expected %v, but got %v", expected, environ)
+	}
+}
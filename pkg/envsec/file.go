@@ -0,0 +1,62 @@
+package envsec
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// defaultFileMode is the permission used for files that contain secret values.
+const defaultFileMode os.FileMode = 0o600
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path and then renames it into place, so readers never observe a partially
+// written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if perm == 0 {
+		perm = defaultFileMode
+	}
+	// Renaming over a symlink would replace the link, so write its target.
+	path = resolveSymlinks(path)
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Removing the temp file is a no-op once it has been renamed.
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), path))
+}
+
+// resolveSymlinks returns the file that path links to, following links whose
+// targets don't exist yet too. It returns path if it isn't a link.
+func resolveSymlinks(path string) string {
+	// Give up on link loops like the OS does.
+	for i := 0; i < 40; i++ {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return path
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return path
+}
@@ -0,0 +1,143 @@
+package envsec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/pkg/fileutil"
+)
+
+// fileEnvSuffix is appended to the name of a variable that is materialized as
+// a file, to form the name of the variable that holds the path of the file.
+const fileEnvSuffix = "_FILE"
+
+// ParseFileMappings parses NAME[:path] arguments into a map of variable names
+// to file paths. The path is relative to the private directory the files are
+// written to, and defaults to NAME.
+func ParseFileMappings(args []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, arg := range args {
+		name, path, _ := strings.Cut(arg, ":")
+		if path == "" {
+			path = name
+		}
+		mapping[name] = path
+	}
+	return mapping, validateFileMapping(mapping)
+}
+
+// LoadFileManifest reads a JSON, YAML or TOML file that maps variable names to file
+// paths, as with ParseFileMappings.
+func LoadFileManifest(path string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if err := fileutil.UnmarshalFile(path, &mapping); err != nil {
+		return nil, errors.Wrapf(err, "failed to load file manifest %s", path)
+	}
+	for name, p := range mapping {
+		if p == "" {
+			mapping[name] = name
+		}
+	}
+	return mapping, validateFileMapping(mapping)
+}
+
+func validateFileMapping(mapping map[string]string) error {
+	if err := ensureValidNames(lo.Keys(mapping)); err != nil {
+		return err
+	}
+	for name, path := range mapping {
+		clean := filepath.Clean(path)
+		if filepath.IsAbs(clean) || clean == ".." ||
+			strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return errors.Errorf(
+				"path %q for %s must be relative to the secrets directory", path, name)
+		}
+	}
+	return nil
+}
+
+// SecretFiles writes variables into files in a private directory, which is
+// backed by memory (tmpfs) where the platform offers it.
+type SecretFiles struct {
+	Dir     string
+	mapping map[string]string
+}
+
+// NewSecretFiles creates the private directory for the files in mapping.
+// Callers must call Remove once the files are no longer needed.
+func NewSecretFiles(mapping map[string]string) (*SecretFiles, error) {
+	dir, err := os.MkdirTemp(privateTempDir(), "envsec-")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// MkdirTemp already uses 0700, but make sure a umask can't loosen it.
+	if err := os.Chmod(dir, 0o700); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, errors.WithStack(err)
+	}
+	return &SecretFiles{Dir: dir, mapping: mapping}, nil
+}
+
+// Write writes the mapped variables to their files with 0600 permissions. It
+// returns envVars with each mapped variable replaced by a NAME_FILE variable
+// that holds the path of its file.
+func (s *SecretFiles) Write(envVars []EnvVar) ([]EnvVar, error) {
+	values := envVarsToMap(envVars)
+	missing := []string{}
+	for name := range s.mapping {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, errors.Errorf(
+			"cannot write files for variables that are not set: %s",
+			strings.Join(missing, ", "),
+		)
+	}
+
+	result := []EnvVar{}
+	for _, envVar := range envVars {
+		rel, ok := s.mapping[envVar.Name]
+		if !ok {
+			result = append(result, envVar)
+			continue
+		}
+		path := filepath.Join(s.Dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := writeFileAtomic(path, []byte(envVar.Value), defaultFileMode); err != nil {
+			return nil, err
+		}
+		result = append(result, EnvVar{Name: envVar.Name + fileEnvSuffix, Value: path})
+	}
+	return result, nil
+}
+
+// Remove deletes the directory and all the files in it.
+func (s *SecretFiles) Remove() error {
+	return errors.WithStack(os.RemoveAll(s.Dir))
+}
+
+// privateTempDir returns a directory for temporary secret files, preferring
+// memory backed file systems so that values are never written to disk.
+func privateTempDir() string {
+	if runtime.GOOS != "linux" {
+		return os.TempDir()
+	}
+	// XDG_RUNTIME_DIR is a per-user tmpfs on systemd based systems.
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && fileutil.IsDir(dir) {
+		return dir
+	}
+	if fileutil.IsDir("/dev/shm") {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,63 @@
+package envsec
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFileMappings(t *testing.T) {
+	mapping, err := ParseFileMappings([]string{"TLS_CERT", "TLS_KEY:tls/key.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"TLS_CERT": "TLS_CERT", "TLS_KEY": "tls/key.pem"}
+	if !reflect.DeepEqual(mapping, expected) {
+		t.Errorf("Expected %v, but got %v", expected, mapping)
+	}
+
+	for _, arg := range []string{"KEY:/etc/key", "KEY:../key", "1KEY"} {
+		if _, err := ParseFileMappings([]string{arg}); err == nil {
+			t.Errorf("Expected an error for %q", arg)
+		}
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	files, err := NewSecretFiles(map[string]string{"KEY": "tls/key.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envVars, err := files.Write([]EnvVar{{"A", "1"}, {"KEY", "secret\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(files.Dir, "tls", "key.pem")
+	expected := []EnvVar{{"A", "1"}, {"KEY_FILE", path}}
+	if !reflect.DeepEqual(envVars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, envVars)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, but got %v", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "secret\n" {
+		t.Errorf("Expected file to contain the value, but got %q", data)
+	}
+
+	if _, err := files.Write([]EnvVar{{"A", "1"}}); err == nil {
+		t.Error("Expected an error for a missing variable")
+	}
+
+	if err := files.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(files.Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", files.Dir)
+	}
+}
@@ -0,0 +1,321 @@
+package envsec
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Format describes a file format that environment variables can be exported
+// to, and optionally uploaded from. Formats are looked up by name, alias or
+// file extension.
+type Format struct {
+	// Name is the canonical name used with --format.
+	Name string
+	// Aliases are alternative names accepted with --format.
+	Aliases []string
+	// Extensions are the file extensions (including the dot) used to infer the
+	// format from a path when no format is given.
+	Extensions []string
+	// Encode serializes the variables in the given order.
+	Encode func(envVars []EnvVar) ([]byte, error)
+	// Decode parses a file into a possibly nested document. It is nil for
+	// formats that can't be uploaded.
+	Decode func(data []byte) (map[string]any, error)
+}
+
+var formats = []Format{}
+
+// RegisterFormat adds a format to the registry, replacing any format with the
+// same name. It is meant to be called from init functions, which allows
+// programs that embed envsec to add their own formats.
+func RegisterFormat(f Format) {
+	for i, existing := range formats {
+		if existing.Name == f.Name {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// LookupFormat returns the format with the given name or alias.
+func LookupFormat(name string) (Format, bool) {
+	name = strings.ToLower(name)
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+		for _, alias := range f.Aliases {
+			if alias == name {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// formatForPath returns the format to use for path. An explicit name takes
+// precedence over the extension of path, and dotenv is the fallback.
+func formatForPath(name, path string) Format {
+	if f, ok := LookupFormat(name); ok {
+		return f
+	}
+	if f, ok := formatForExt(path); ok {
+		return f
+	}
+	f, _ := LookupFormat("dotenv")
+	return f
+}
+
+// formatForExt returns the format registered for the extension of path.
+func formatForExt(path string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// FormatNames returns the canonical names of all registered formats.
+func FormatNames() []string {
+	names := []string{}
+	for _, f := range formats {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func ValidateFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	if _, ok := LookupFormat(format); !ok {
+		return errors.Errorf(
+			"incorrect format. Must be one of %s", strings.Join(FormatNames(), "|"))
+	}
+	return nil
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "dotenv",
+		Aliases:    []string{"env"},
+		Extensions: []string{".env"},
+		Encode: func(envVars []EnvVar) ([]byte, error) {
+			return encodeToDotEnv(envVarsToMap(envVars))
+		},
+		Decode: decodeDotEnv,
+	})
+	RegisterFormat(Format{
+		Name:       "json",
+		Extensions: []string{".json"},
+		Encode: func(envVars []EnvVar) ([]byte, error) {
+			return encodeToJSON(envVarsToMap(envVars))
+		},
+		Decode: decodeJSON,
+	})
+	RegisterFormat(Format{
+		Name:       "yaml",
+		Aliases:    []string{"yml"},
+		Extensions: []string{".yaml", ".yml"},
+		Encode:     encodeToYAML,
+		Decode:     decodeYAML,
+	})
+	RegisterFormat(Format{
+		Name:       "toml",
+		Extensions: []string{".toml"},
+		Encode:     encodeToTOML,
+		Decode:     decodeTOML,
+	})
+	RegisterFormat(Format{
+		Name:       "shell",
+		Aliases:    []string{"sh", "bash", "zsh"},
+		Extensions: []string{".sh", ".bash", ".zsh"},
+		Encode:     encodeLines(shellLine),
+	})
+	RegisterFormat(Format{
+		Name:       "fish",
+		Extensions: []string{".fish"},
+		Encode:     encodeLines(fishLine),
+	})
+	RegisterFormat(Format{
+		Name:       "powershell",
+		Aliases:    []string{"pwsh", "ps1"},
+		Extensions: []string{".ps1"},
+		Encode:     encodeLines(powershellLine),
+	})
+	RegisterFormat(Format{
+		Name:    "docker",
+		Aliases: []string{"env-file"},
+		Encode:  encodeLines(dockerLine),
+	})
+	RegisterFormat(Format{
+		Name:   "systemd",
+		Encode: encodeLines(systemdLine),
+	})
+	RegisterFormat(Format{
+		Name:       "properties",
+		Aliases:    []string{"java"},
+		Extensions: []string{".properties"},
+		Encode:     encodeLines(propertiesLine),
+		Decode:     decodeProperties,
+	})
+	RegisterFormat(Format{
+		Name:       "tfvars",
+		Aliases:    []string{"terraform"},
+		Extensions: []string{".tfvars"},
+		Encode:     encodeLines(tfvarsLine),
+	})
+}
+
+func envVarsToMap(envVars []EnvVar) map[string]string {
+	m := map[string]string{}
+	for _, envVar := range envVars {
+		m[envVar.Name] = envVar.Value
+	}
+	return m
+}
+
+// sortedEnvVars returns a copy of envVars sorted by name.
+func sortedEnvVars(envVars []EnvVar) []EnvVar {
+	sorted := append([]EnvVar{}, envVars...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func encodeToYAML(envVars []EnvVar) ([]byte, error) {
+	b := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(envVarsToMap(envVars)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return b.Bytes(), errors.WithStack(encoder.Close())
+}
+
+func encodeToTOML(envVars []EnvVar) ([]byte, error) {
+	data, err := toml.Marshal(envVarsToMap(envVars))
+	return data, errors.WithStack(err)
+}
+
+// encodeLines returns an encoder that writes one line per variable.
+func encodeLines(line func(envVar EnvVar) (string, error)) func([]EnvVar) ([]byte, error) {
+	return func(envVars []EnvVar) ([]byte, error) {
+		b := strings.Builder{}
+		for _, envVar := range envVars {
+			l, err := line(envVar)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(l)
+			b.WriteString("\n")
+		}
+		return []byte(b.String()), nil
+	}
+}
+
+func shellLine(envVar EnvVar) (string, error) {
+	// Single quotes disable all expansion, the only character that needs
+	// special handling is the single quote itself.
+	value := strings.ReplaceAll(envVar.Value, "'", `'\''`)
+	return fmt.Sprintf("export %s='%s'", envVar.Name, value), nil
+}
+
+func fishLine(envVar EnvVar) (string, error) {
+	value := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(envVar.Value)
+	return fmt.Sprintf("set -gx %s '%s'", envVar.Name, value), nil
+}
+
+func powershellLine(envVar EnvVar) (string, error) {
+	value := strings.ReplaceAll(envVar.Value, "'", "''")
+	return fmt.Sprintf("$env:%s = '%s'", envVar.Name, value), nil
+}
+
+func dockerLine(envVar EnvVar) (string, error) {
+	// docker --env-file takes values verbatim and has no quoting or escaping,
+	// so there is no way to represent a line break.
+	if strings.ContainsAny(envVar.Value, "\r\n") {
+		return "", errors.Errorf(
+			"value of %s contains a line break, which docker env files do not support",
+			envVar.Name,
+		)
+	}
+	return envVar.Name + "=" + envVar.Value, nil
+}
+
+func systemdLine(envVar EnvVar) (string, error) {
+	if strings.ContainsAny(envVar.Value, "\r\n") {
+		return "", errors.Errorf(
+			"value of %s contains a line break, which systemd environment files do not support",
+			envVar.Name,
+		)
+	}
+	value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(envVar.Value)
+	return fmt.Sprintf("%s=\"%s\"", envVar.Name, value), nil
+}
+
+func propertiesLine(envVar EnvVar) (string, error) {
+	return escapeProperty(envVar.Name, true) + "=" + escapeProperty(envVar.Value, false), nil
+}
+
+// escapeProperty escapes s following the rules of java.util.Properties.
+// Keys additionally escape all whitespace and separators.
+func escapeProperty(s string, isKey bool) string {
+	b := strings.Builder{}
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case r < 0x20 || r > 0x7e:
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func tfvarsLine(envVar EnvVar) (string, error) {
+	value := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"${", "$${",
+		"%{", "%%{",
+	).Replace(envVar.Value)
+	return fmt.Sprintf("%s = \"%s\"", envVar.Name, value), nil
+}
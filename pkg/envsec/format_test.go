@@ -0,0 +1,72 @@
+package envsec
+
+import (
+	"testing"
+)
+
+func TestFormatEncode(t *testing.T) {
+	envVars := []EnvVar{
+		{Name: "A", Value: "it's"},
+		{Name: "B", Value: "x=${y}\n"},
+	}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"shell", "export A='it'\\''s'\nexport B='x=${y}\n'\n"},
+		{"fish", "set -gx A 'it\\'s'\nset -gx B 'x=${y}\n'\n"},
+		{"powershell", "$env:A = 'it''s'\n$env:B = 'x=${y}\n'\n"},
+		{"properties", "A=it's\nB=x\\=${y}\\n\n"},
+		{"tfvars", "A = \"it's\"\nB = \"x=$${y}\\n\"\n"},
+		{"yaml", "A: it's\nB: |\n  x=${y}\n"},
+		{"toml", "A = \"it's\"\nB = \"x=${y}\\n\"\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			f, ok := LookupFormat(test.format)
+			if !ok {
+				t.Fatalf("format %s is not registered", test.format)
+			}
+			result, err := f.Encode(envVars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != test.expected {
+				t.Errorf("Expected %q, but got %q", test.expected, string(result))
+			}
+		})
+	}
+}
+
+func TestFormatRejectsLineBreaks(t *testing.T) {
+	for _, name := range []string{"docker", "systemd"} {
+		f, _ := LookupFormat(name)
+		if _, err := f.Encode([]EnvVar{{Name: "A", Value: "a\nb"}}); err == nil {
+			t.Errorf("Expected %s to reject values with line breaks", name)
+		}
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	tests := []struct {
+		format   string
+		path     string
+		expected string
+	}{
+		{"", "secrets.yml", "yaml"},
+		{"", "app.properties", "properties"},
+		{"", ".env", "dotenv"},
+		{"", "unknown.txt", "dotenv"},
+		{"env", "out.json", "dotenv"},
+		{"bash", "-", "shell"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if f := formatForPath(test.format, test.path); f.Name != test.expected {
+				t.Errorf("Expected %s, but got %s", test.expected, f.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package envsec
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// Charset is the set of characters, or words, that generated secrets are
+// made of.
+type Charset string
+
+const (
+	CharsetAlnum   Charset = "alnum"
+	CharsetHex     Charset = "hex"
+	CharsetBase64  Charset = "base64"
+	CharsetURLSafe Charset = "urlsafe"
+	// CharsetWords generates a passphrase of dash separated words.
+	CharsetWords Charset = "words"
+)
+
+// Charsets are all the supported charsets.
+var Charsets = []Charset{CharsetAlnum, CharsetHex, CharsetBase64, CharsetURLSafe, CharsetWords}
+
+const (
+	defaultSecretLength = 32
+	defaultSecretWords  = 6
+)
+
+var charsetAlphabets = map[Charset]string{
+	CharsetAlnum:   "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	CharsetHex:     "0123456789abcdef",
+	CharsetBase64:  "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/",
+	CharsetURLSafe: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+}
+
+// wordlist is the BIP39 list of 2048 English words.
+//
+//go:embed wordlist.txt
+var wordlist string
+
+type GenerateOptions struct {
+	// Length is the number of characters, or of words for CharsetWords, not
+	// counting Prefix. Defaults to 32 characters or 6 words.
+	Length int
+	// Charset defaults to CharsetAlnum.
+	Charset Charset
+	// Prefix is prepended to the random value, e.g. "sk_".
+	Prefix string
+	// SkipExisting leaves the variable unchanged if it is already set.
+	SkipExisting bool
+	// Show prints the generated value to Stdout.
+	Show bool
+}
+
+// Generate sets the variable name to a cryptographically random value. The
+// value is only printed if opts.Show is set.
+func (e *Envsec) Generate(ctx context.Context, name string, opts GenerateOptions) error {
+	if opts.SkipExisting {
+		existing, err := e.Store.GetAll(ctx, e.EnvID, []string{name})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(existing) > 0 {
+			return tux.WriteHeader(e.Stderr,
+				"[DONE] Kept existing environment variable '%s' in environment: %s\n",
+				name,
+				e.EnvID.EnvName,
+			)
+		}
+	}
+	value, err := GenerateSecret(opts.Length, opts.Charset)
+	if err != nil {
+		return err
+	}
+	value = opts.Prefix + value
+	if err := e.Set(ctx, name, value); err != nil {
+		return err
+	}
+	if opts.Show {
+		_, err := fmt.Fprintln(e.stdout(), value)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// GenerateSecret returns a cryptographically random string of length
+// characters from charset, or of length words for CharsetWords. A length of
+// 0 uses the default.
+func GenerateSecret(length int, charset Charset) (string, error) {
+	if charset == "" {
+		charset = CharsetAlnum
+	}
+	if length < 0 {
+		return "", errors.Errorf("length must be positive, but got %d", length)
+	}
+
+	if charset == CharsetWords {
+		if length == 0 {
+			length = defaultSecretWords
+		}
+		words := strings.Fields(wordlist)
+		chosen := make([]string, length)
+		for i := range chosen {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			chosen[i] = words[n.Int64()]
+		}
+		return strings.Join(chosen, "-"), nil
+	}
+
+	alphabet, ok := charsetAlphabets[charset]
+	if !ok {
+		return "", errors.Errorf("unknown charset %q. Must be one of: %v", charset, Charsets)
+	}
+	if length == 0 {
+		length = defaultSecretLength
+	}
+	result := make([]byte, length)
+	for i := range result {
+		// rand.Int is uniform, unlike taking random bytes modulo the size of
+		// the alphabet.
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		result[i] = alphabet[n.Int64()]
+	}
+	return string(result), nil
+}
@@ -0,0 +1,75 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	tests := []struct {
+		length  int
+		charset Charset
+		want    string
+	}{
+		{0, "", `^[A-Za-z0-9]{32}$`},
+		{16, CharsetHex, `^[0-9a-f]{16}$`},
+		{20, CharsetBase64, `^[A-Za-z0-9+/]{20}$`},
+		{20, CharsetURLSafe, `^[A-Za-z0-9_-]{20}$`},
+		{0, CharsetWords, `^[a-z]+(-[a-z]+){5}$`},
+		{3, CharsetWords, `^[a-z]+(-[a-z]+){2}$`},
+	}
+	for _, test := range tests {
+		got, err := GenerateSecret(test.length, test.charset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(test.want).MatchString(got) {
+			t.Errorf("Expected %d %s to match %s, but got %q", test.length, test.charset, test.want, got)
+		}
+	}
+	if _, err := GenerateSecret(8, "emoji"); err == nil {
+		t.Error("Expected an unknown charset to fail")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	stdout := &bytes.Buffer{}
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		Stdout:     stdout,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.Generate(ctx, "TOKEN", GenerateOptions{Prefix: "sk_"}); err != nil {
+		t.Fatal(err)
+	}
+	token := store.env(e.EnvID)["TOKEN"]
+	if !strings.HasPrefix(token, "sk_") || len(token) != 35 {
+		t.Errorf("Expected a prefixed 32 character token, but got %q", token)
+	}
+	if stdout.Len() != 0 {
+		t.Error("Expected the value not to be printed without Show")
+	}
+
+	err := e.Generate(ctx, "TOKEN", GenerateOptions{SkipExisting: true, Show: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.env(e.EnvID)["TOKEN"] != token {
+		t.Error("Expected an existing value to be kept")
+	}
+
+	if err := e.Generate(ctx, "OTHER", GenerateOptions{Show: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != store.env(e.EnvID)["OTHER"] {
+		t.Errorf("Expected the generated value to be shown, but got %q", got)
+	}
+}
@@ -0,0 +1,300 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/k8s"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// Importer parses the secrets exported by another secret manager.
+type Importer struct {
+	// Name is the name used with --from.
+	Name string
+	// Description says which export the importer reads, e.g. the command that
+	// creates it.
+	Description string
+	// Parse returns the variables in an export. Their names are validated
+	// like any other variable before they are imported.
+	Parse func(data []byte) (map[string]string, error)
+}
+
+var importers = []Importer{}
+
+// RegisterImporter adds an importer, replacing any importer with the same
+// name. Like RegisterFormat, it lets programs that embed envsec import from
+// other secret managers.
+func RegisterImporter(i Importer) {
+	for j, existing := range importers {
+		if existing.Name == i.Name {
+			importers[j] = i
+			return
+		}
+	}
+	importers = append(importers, i)
+}
+
+// LookupImporter returns the importer with the given name.
+func LookupImporter(name string) (Importer, bool) {
+	return lo.Find(importers, func(i Importer) bool {
+		return i.Name == strings.ToLower(name)
+	})
+}
+
+// Importers returns all registered importers, sorted by name.
+func Importers() []Importer {
+	result := append([]Importer{}, importers...)
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func init() {
+	RegisterImporter(Importer{
+		Name:        "heroku",
+		Description: "heroku config --json",
+		Parse:       parseFlatJSON,
+	})
+	RegisterImporter(Importer{
+		Name:        "vercel",
+		Description: "vercel env pull",
+		Parse:       parseDotEnvExport,
+	})
+	RegisterImporter(Importer{
+		Name:        "doppler",
+		Description: "doppler secrets download --no-file --format json, or doppler secrets --json",
+		Parse:       parseDopplerJSON,
+	})
+	RegisterImporter(Importer{
+		Name:        "infisical",
+		Description: "infisical export --format json",
+		Parse:       parseInfisicalJSON,
+	})
+	RegisterImporter(Importer{
+		Name:        "1password",
+		Description: "op item get <item> --format json",
+		Parse:       parse1PasswordItem,
+	})
+	RegisterImporter(Importer{
+		Name:        "k8s",
+		Description: "a Kubernetes Secret manifest in YAML or JSON",
+		Parse:       parseK8sSecret,
+	})
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// From is the name of the importer.
+	From string
+	// DryRun only previews the changes.
+	DryRun bool
+}
+
+// Import sets the variables in an export of another secret manager. It
+// previews the variables that would be added or changed, and asks for
+// confirmation before writing them with SetMap.
+func (e *Envsec) Import(ctx context.Context, path string, opts ImportOptions) error {
+	importer, ok := LookupImporter(opts.From)
+	if !ok {
+		return errors.Errorf("unknown import source %q. Must be one of: %s",
+			opts.From,
+			strings.Join(lo.Map(Importers(), func(i Importer, _ int) string { return i.Name }), ", "),
+		)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	values, err := importer.Parse(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to import %s from %s", path, importer.Name)
+	}
+	// Report invalid names before the preview rather than after confirming.
+	if err := ensureValidNames(lo.Keys(values)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	envVars, err := e.Store.List(ctx, e.EnvID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	current := map[string]string{}
+	for _, envVar := range envVars {
+		if !isReservedName(envVar.Name) {
+			current[envVar.Name] = envVar.Value
+		}
+	}
+	changes := DiffEnvVars(current, values, false /*prune*/)
+	err = tux.WriteHeader(e.Stderr,
+		"Importing %d %s from %s into environment: %s\n",
+		len(values),
+		tux.Plural(lo.Keys(values), "variable", "variables"),
+		path,
+		e.EnvID.EnvName,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := PrintChanges(e.Stderr, changes); err != nil {
+		return err
+	}
+	if opts.DryRun || len(changes) == 0 {
+		return nil
+	}
+
+	// Protected environments are confirmed by SetMap.
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	if cfg := environments[e.EnvID.EnvName]; !cfg.Protected && !cfg.Frozen {
+		ok, err := e.askYesNo(fmt.Sprintf("Import %d %s?",
+			len(changes), tux.Plural(changes, "change", "changes")))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+	toSet := map[string]string{}
+	for _, change := range changes {
+		toSet[change.Name] = values[change.Name]
+	}
+	return e.SetMap(ctx, toSet)
+}
+
+// parseFlatJSON parses a JSON object of names to values.
+func parseFlatJSON(data []byte) (map[string]string, error) {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for name, v := range doc {
+		value, err := scalarString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for %s", name)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func parseDotEnvExport(data []byte) (map[string]string, error) {
+	doc, err := decodeDotEnv(data)
+	if err != nil {
+		return nil, err
+	}
+	return lo.MapValues(doc, func(v any, _ string) string { return v.(string) }), nil
+}
+
+// dopplerMetadataNames are the secrets that Doppler adds to every config to
+// describe it. They aren't imported.
+var dopplerMetadataNames = []string{"DOPPLER_PROJECT", "DOPPLER_CONFIG", "DOPPLER_ENVIRONMENT"}
+
+// parseDopplerJSON parses the flat JSON of doppler secrets download, or the
+// JSON of doppler secrets --json, which maps names to objects with the
+// computed value of each secret.
+func parseDopplerJSON(data []byte) (map[string]string, error) {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for name, v := range doc {
+		if lo.Contains(dopplerMetadataNames, name) {
+			continue
+		}
+		if secret, ok := v.(map[string]any); ok {
+			v = secret["computed"]
+		}
+		value, err := scalarString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for %s", name)
+		}
+		values[name] = value
+	}
+	return renameKeys(values, normalizeName)
+}
+
+// parseK8sSecret parses a Secret manifest. Its keys are often file names, such
+// as tls.crt, so they are turned into names like TLS_CRT.
+func parseK8sSecret(data []byte) (map[string]string, error) {
+	values, err := k8s.ParseSecret(data)
+	if err != nil {
+		return nil, err
+	}
+	return renameKeys(values, func(key string) string {
+		return strings.ToUpper(normalizeName(key))
+	})
+}
+
+// renameKeys renames the keys of values, and fails if two of them get the
+// same name.
+func renameKeys(values map[string]string, rename func(string) string) (map[string]string, error) {
+	keys := lo.Keys(values)
+	sort.Strings(keys)
+	result := map[string]string{}
+	for _, key := range keys {
+		name := rename(key)
+		if _, ok := result[name]; ok {
+			return nil, errors.Errorf("more than one key maps to the name %s", name)
+		}
+		result[name] = values[key]
+	}
+	return result, nil
+}
+
+// parseInfisicalJSON parses the JSON array of infisical export, whose
+// entries have a key and a value.
+func parseInfisicalJSON(data []byte) (map[string]string, error) {
+	secrets := []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}{}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := map[string]string{}
+	for _, secret := range secrets {
+		if _, ok := values[secret.Key]; ok {
+			return nil, errors.Errorf("secret %s appears more than once", secret.Key)
+		}
+		values[secret.Key] = secret.Value
+	}
+	return values, nil
+}
+
+// parse1PasswordItem parses a 1Password item. Each field with a value
+// becomes a variable named after its label, e.g. "api key" becomes API_KEY.
+func parse1PasswordItem(data []byte) (map[string]string, error) {
+	item := struct {
+		Fields []struct {
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}{}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := map[string]string{}
+	for _, field := range item.Fields {
+		if field.Value == "" {
+			continue
+		}
+		name := strings.Trim(nonNameCharsRegex.ReplaceAllString(field.Label, "_"), "_")
+		name = strings.ToUpper(name)
+		if _, ok := values[name]; ok {
+			return nil, errors.Errorf("more than one field maps to the name %s", name)
+		}
+		values[name] = field.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,119 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImporters(t *testing.T) {
+	tests := []struct {
+		from string
+		data string
+		want map[string]string
+	}{
+		{
+			from: "heroku",
+			data: `{"DATABASE_URL": "postgres://db", "WEB_CONCURRENCY": 2}`,
+			want: map[string]string{"DATABASE_URL": "postgres://db", "WEB_CONCURRENCY": "2"},
+		},
+		{
+			from: "vercel",
+			data: "# Created by Vercel CLI\nAPI_KEY=\"abc\"\nVERCEL_ENV=\"development\"\n",
+			want: map[string]string{"API_KEY": "abc", "VERCEL_ENV": "development"},
+		},
+		{
+			from: "doppler",
+			data: `{"API_KEY": {"computed": "abc", "raw": "${OTHER}", "note": ""}, "PORT": "80"}`,
+			want: map[string]string{"API_KEY": "abc", "PORT": "80"},
+		},
+		{
+			from: "doppler",
+			data: `{"API-KEY": "abc", "DOPPLER_PROJECT": "app", "DOPPLER_CONFIG": "dev",
+				"DOPPLER_ENVIRONMENT": "dev"}`,
+			want: map[string]string{"API_KEY": "abc"},
+		},
+		{
+			from: "infisical",
+			data: `[{"key": "API_KEY", "value": "abc", "type": "shared"}]`,
+			want: map[string]string{"API_KEY": "abc"},
+		},
+		{
+			from: "1password",
+			data: `{"title": "Stripe", "fields": [
+				{"id": "username", "label": "username", "value": "me"},
+				{"id": "notesPlain", "label": "notesPlain", "purpose": "NOTES"},
+				{"id": "x", "label": "secret key", "type": "CONCEALED", "value": "sk_123"}
+			]}`,
+			want: map[string]string{"USERNAME": "me", "SECRET_KEY": "sk_123"},
+		},
+		{
+			from: "k8s",
+			data: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\n" +
+				"data:\n  API_KEY: YWJj\n  PORT: ODA=\nstringData:\n  PORT: \"8080\"\n",
+			want: map[string]string{"API_KEY": "abc", "PORT": "8080"},
+		},
+		{
+			from: "k8s",
+			data: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: tls\n" +
+				"stringData:\n  tls.crt: cert\n  config.yaml: \"a: b\"\n",
+			want: map[string]string{"TLS_CRT": "cert", "CONFIG_YAML": "a: b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.from, func(t *testing.T) {
+			importer, ok := LookupImporter(test.from)
+			if !ok {
+				t.Fatalf("Expected an importer named %s", test.from)
+			}
+			got, err := importer.Parse([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %v, but got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	path := filepath.Join(e.WorkingDir, "heroku.json")
+	if err := os.WriteFile(path, []byte(`{"A": "1", "B": "2"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Import(ctx, path, ImportOptions{From: "heroku", DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.env(e.EnvID)) != 0 {
+		t.Error("Expected a dry run to import nothing")
+	}
+
+	e.AssumeYes = true
+	if err := e.Import(ctx, path, ImportOptions{From: "heroku"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": "2"}
+	if got := store.env(e.EnvID); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"JETPACK_X": "1"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Import(ctx, path, ImportOptions{From: "heroku"}); err == nil {
+		t.Error("Expected invalid names to be rejected")
+	}
+}
@@ -27,6 +27,20 @@ const (
 type projectConfig struct {
 	ProjectID ids.ProjectID `json:"project_id"`
 	OrgID     ids.OrgID     `json:"org_id"`
+	// Environments is only read from configs written before the environments
+	// moved to environmentsName.
+	Environments map[string]EnvironmentConfig `json:"environments,omitempty"`
+}
+
+type EnvironmentConfig struct {
+	// Extends is the name of the environment this one inherits variables from.
+	// Variables set in this environment override the inherited ones.
+	Extends string `json:"extends,omitempty"`
+	// Protected environments require confirmation before their variables are
+	// changed or deleted.
+	Protected bool `json:"protected,omitempty"`
+	// Frozen environments are read-only until they are unfrozen.
+	Frozen bool `json:"frozen,omitempty"`
 }
 
 func (e *Envsec) NewProject(ctx context.Context, force bool) error {
@@ -57,7 +71,7 @@ func (e *Envsec) NewProject(ctx context.Context, force bool) error {
 		return err
 	}
 
-	if err = git.CreateGitIgnore(dirPath); err != nil {
+	if err = git.CreateGitIgnore(dirPath, environmentsName); err != nil {
 		return err
 	}
 
@@ -95,6 +109,14 @@ func (e *Envsec) configName() string {
 
 func (e *Envsec) saveConfig(projectID ids.ProjectID, orgID ids.OrgID) error {
 	cfg := projectConfig{ProjectID: projectID, OrgID: orgID}
+	// Keep the environments when the directory is initialized again.
+	if existing, err := e.ProjectConfig(); err == nil {
+		cfg.Environments = existing.Environments
+	}
+	return e.writeConfig(&cfg)
+}
+
+func (e *Envsec) writeConfig(cfg *projectConfig) error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
@@ -0,0 +1,167 @@
+package envsec
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/k8s"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+type K8sExportOptions struct {
+	// Name of the generated Secret.
+	Name      string
+	Namespace string
+	// Labels and Annotations are added to every generated manifest.
+	Labels      map[string]string
+	Annotations map[string]string
+	// StringData stores values verbatim in stringData instead of base64
+	// encoding them into data.
+	StringData bool
+	// ConfigMapKeys are glob patterns of non-sensitive variables that are
+	// split into a ConfigMap instead of the Secret.
+	ConfigMapKeys []string
+	// ConfigMapName defaults to Name with a "-config" suffix.
+	ConfigMapName string
+	// OutputDir, if set, receives one file per manifest plus a
+	// kustomization.yaml. Otherwise manifests are written to stdout.
+	OutputDir string
+	// SealedSecretsCert is the path to the public certificate of a
+	// sealed-secrets controller. If set, a SealedSecret is generated instead of
+	// a Secret.
+	SealedSecretsCert  string
+	SealedSecretsScope string
+}
+
+// ExportK8s renders the environment variables as Kubernetes manifests.
+func (e *Envsec) ExportK8s(ctx context.Context, opts K8sExportOptions) error {
+	if opts.Name == "" {
+		return errors.New("a name is required for the Kubernetes secret")
+	}
+	if opts.SealedSecretsCert != "" && opts.StringData {
+		return errors.New("sealed secrets cannot be combined with stringData")
+	}
+	scope, err := k8s.ParseScope(opts.SealedSecretsScope)
+	if err != nil {
+		return err
+	}
+
+	envVars, err := e.List(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	secretValues := map[string]string{}
+	configValues := map[string]string{}
+	for _, envVar := range envVars {
+		if matchesAny(envVar.Name, opts.ConfigMapKeys) {
+			configValues[envVar.Name] = envVar.Value
+		} else {
+			secretValues[envVar.Name] = envVar.Value
+		}
+	}
+
+	meta := k8s.Metadata{
+		Name:        opts.Name,
+		Namespace:   opts.Namespace,
+		Labels:      opts.Labels,
+		Annotations: opts.Annotations,
+	}
+
+	type manifest struct {
+		file string
+		obj  any
+	}
+	manifests := []manifest{}
+	if opts.SealedSecretsCert != "" {
+		certPath := opts.SealedSecretsCert
+		if !filepath.IsAbs(certPath) {
+			certPath = filepath.Join(e.WorkingDir, certPath)
+		}
+		cert, err := os.ReadFile(certPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pubKey, err := k8s.ParsePublicKey(cert)
+		if err != nil {
+			return err
+		}
+		sealed, err := k8s.NewSealedSecret(meta, secretValues, pubKey, scope)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifest{"sealedsecret.yaml", sealed})
+	} else {
+		manifests = append(manifests, manifest{
+			"secret.yaml", k8s.NewSecret(meta, secretValues, opts.StringData),
+		})
+	}
+	if len(configValues) > 0 {
+		configMeta := meta
+		configMeta.Name = opts.ConfigMapName
+		if configMeta.Name == "" {
+			configMeta.Name = opts.Name + "-config"
+		}
+		manifests = append(manifests, manifest{
+			"configmap.yaml", k8s.NewConfigMap(configMeta, configValues),
+		})
+	}
+
+	if opts.OutputDir == "" {
+		objs := []any{}
+		for _, m := range manifests {
+			objs = append(objs, m.obj)
+		}
+		data, err := k8s.Marshal(objs...)
+		if err != nil {
+			return err
+		}
+		_, err = e.stdout().Write(data)
+		return errors.WithStack(err)
+	}
+
+	dir := opts.OutputDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(e.WorkingDir, dir)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	resources := []string{}
+	for _, m := range manifests {
+		data, err := k8s.Marshal(m.obj)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(dir, m.file), data, 0); err != nil {
+			return err
+		}
+		resources = append(resources, m.file)
+	}
+	data, err := k8s.Marshal(k8s.NewKustomization(opts.Namespace, resources))
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "kustomization.yaml"), data, 0); err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Exported environment variables to %q for environment: %s\n",
+		dir,
+		strings.ToLower(e.EnvID.EnvName),
+	)
+}
+
+// matchesAny reports whether name matches any of the glob patterns.
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package envsec
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LayeredEnvVar is an environment variable along with the layer its
+// effective value comes from.
+type LayeredEnvVar struct {
+	EnvVar
+	// Layer is the name of the environment that sets the value, or
+	// LocalLayer for local overrides.
+	Layer string
+	// Overrides is the layer whose value is overridden by this one, if any.
+	Overrides string
+}
+
+// EnvironmentLayers expands an ordered list of environment names, from lowest
+// to highest precedence, with the environments each of them extends. If an
+// environment appears more than once, only its highest precedence occurrence
+// is kept.
+func (e *Envsec) EnvironmentLayers(names []string) ([]string, error) {
+	environments, err := e.Environments()
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		chain := []string{name}
+		for parent := environments[name].Extends; parent != ""; parent = environments[parent].Extends {
+			for _, seen := range chain {
+				if seen == parent {
+					return nil, errors.Errorf(
+						"environment %q extends itself: %s -> %s",
+						name,
+						strings.Join(chain, " -> "),
+						parent,
+					)
+				}
+			}
+			chain = append(chain, parent)
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			expanded = append(expanded, chain[i])
+		}
+	}
+
+	if len(expanded) == 0 {
+		return nil, errors.New("environment name can not be empty")
+	}
+	result := []string{}
+	seen := map[string]bool{}
+	for i := len(expanded) - 1; i >= 0; i-- {
+		if !seen[expanded[i]] {
+			seen[expanded[i]] = true
+			result = append([]string{expanded[i]}, result...)
+		}
+	}
+	return result, nil
+}
+
+// ListLayered lists the effective environment variables of the environment,
+// resolved across Layers, the environment itself and the local overrides in
+// LocalFile, along with the layer that sets each of them.
+func (e *Envsec) ListLayered(ctx context.Context) ([]LayeredEnvVar, error) {
+	local, err := e.LocalOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	effective := map[string]LayeredEnvVar{}
+	set := func(envVar EnvVar, layer string) {
+		layered := LayeredEnvVar{EnvVar: envVar, Layer: layer}
+		if previous, ok := effective[envVar.Name]; ok {
+			layered.Overrides = previous.Layer
+		}
+		effective[envVar.Name] = layered
+	}
+	for _, layer := range e.LayerNames() {
+		envID := e.EnvID
+		envID.EnvName = layer
+		envVars, err := e.Store.List(ctx, envID)
+		if err != nil {
+			if len(e.Layers) == 0 {
+				return nil, err
+			}
+			return nil, errors.Wrapf(err, "failed to list environment %s", layer)
+		}
+		for _, envVar := range envVars {
+			if !isReservedName(envVar.Name) {
+				set(envVar, layer)
+			}
+		}
+	}
+	for name, value := range local {
+		set(EnvVar{Name: name, Value: value}, LocalLayer)
+	}
+
+	result := make([]LayeredEnvVar, 0, len(effective))
+	for _, envVar := range effective {
+		result = append(result, envVar)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// LayerNames returns the names of all the layers of the environment, from
+// lowest to highest precedence, ending with the environment itself.
+func (e *Envsec) LayerNames() []string {
+	return append(append([]string{}, e.Layers...), e.EnvID.EnvName)
+}
@@ -0,0 +1,81 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentLayers(t *testing.T) {
+	e := &Envsec{WorkingDir: t.TempDir()}
+	if err := e.writeConfigForTest(map[string]EnvironmentConfig{
+		"preview": {Extends: "dev"},
+		"staging": {Extends: "preview"},
+		"a":       {Extends: "b"},
+		"b":       {Extends: "a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		names    []string
+		expected []string
+	}{
+		{[]string{"dev"}, []string{"dev"}},
+		{[]string{"staging"}, []string{"dev", "preview", "staging"}},
+		{[]string{"dev", "preview"}, []string{"dev", "preview"}},
+		{[]string{"preview", "dev"}, []string{"preview", "dev"}},
+		{[]string{"prod", " preview "}, []string{"prod", "dev", "preview"}},
+	}
+	for _, test := range tests {
+		layers, err := e.EnvironmentLayers(test.names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(layers, test.expected) {
+			t.Errorf("EnvironmentLayers(%v) = %v, expected %v", test.names, layers, test.expected)
+		}
+	}
+
+	if _, err := e.EnvironmentLayers([]string{"a"}); err == nil {
+		t.Error("Expected an error for environments that extend each other")
+	}
+}
+
+func TestListLayered(t *testing.T) {
+	store := newMemStore()
+	dev := EnvID{ProjectID: "proj", EnvName: "dev"}
+	preview := EnvID{ProjectID: "proj", EnvName: "preview"}
+	store.env(dev)["A"] = "dev-a"
+	store.env(dev)["B"] = "dev-b"
+	store.env(preview)["B"] = "preview-b"
+	store.env(preview)["C"] = "preview-c"
+
+	e := &Envsec{
+		Store:      store,
+		EnvID:      preview,
+		Layers:     []string{"dev"},
+		LocalFile:  DefaultLocalFile,
+		WorkingDir: t.TempDir(),
+		Stderr:     io.Discard,
+	}
+	if err := e.SetLocal(map[string]string{"C": "local-c", "D": "local-d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteLocal("D"); err != nil {
+		t.Fatal(err)
+	}
+	envVars, err := e.ListLayered(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []LayeredEnvVar{
+		{EnvVar{"A", "dev-a"}, "dev", ""},
+		{EnvVar{"B", "preview-b"}, "preview", "dev"},
+		{EnvVar{"C", "local-c"}, "local", "preview"},
+	}
+	if !reflect.DeepEqual(envVars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, envVars)
+	}
+}
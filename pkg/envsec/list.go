@@ -6,14 +6,25 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	"go.jetify.com/envsec/internal/tux"
 )
 
+// List lists the effective environment variables of the environment. See
+// ListLayered.
 func (e *Envsec) List(ctx context.Context) ([]EnvVar, error) {
-	return e.Store.List(ctx, e.EnvID)
+	layered, err := e.ListLayered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	envVars := make([]EnvVar, len(layered))
+	for i, envVar := range layered {
+		envVars[i] = envVar.EnvVar
+	}
+	return envVars, nil
 }
 
 func PrintEnvVar(
@@ -28,7 +39,7 @@ func PrintEnvVar(
 	for _, envVar := range envVars {
 		valueToPrint := "*****"
 		if expose {
-			valueToPrint = envVar.Value
+			valueToPrint = printableValue(envVar.Value)
 		}
 		envVarsMaskedValue = append(envVarsMaskedValue, EnvVar{
 			Name:  envVar.Name,
@@ -45,8 +56,109 @@ func PrintEnvVar(
 	case "json":
 		return printJSONFormat(envVarsMaskedValue)
 	default:
-		return errors.New("incorrect format. Must be one of table|dotenv|json")
+		f, ok := LookupFormat(format)
+		if !ok {
+			return errors.Errorf(
+				"incorrect format. Must be one of table|%s",
+				strings.Join(FormatNames(), "|"),
+			)
+		}
+		data, err := f.Encode(envVarsMaskedValue)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return errors.WithStack(err)
+	}
+}
+
+// PrintLayeredEnvVars prints envVars like PrintEnvVar. The table format
+// shows the layer each value comes from, and layers lists all the layers in
+// the header.
+func PrintLayeredEnvVars(
+	w io.Writer,
+	layers []string,
+	envVars []LayeredEnvVar,
+	expose bool,
+	format string,
+) error {
+	if format != "table" {
+		plain := make([]EnvVar, len(envVars))
+		for i, envVar := range envVars {
+			plain[i] = envVar.EnvVar
+		}
+		return PrintEnvVar(w, EnvID{}, plain, expose, format)
+	}
+
+	err := tux.WriteHeader(w, "Environment: %s\n", strings.ToLower(strings.Join(layers, ", ")))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	table := tablewriter.NewWriter(w)
+	table.Header("Name", "Value", "Layer")
+	tableValues := [][]string{}
+	for _, envVar := range envVars {
+		value := "*****"
+		if expose {
+			value = printableValue(envVar.Value)
+		}
+		layer := envVar.Layer
+		if envVar.Overrides != "" {
+			layer += " (overrides " + envVar.Overrides + ")"
+		}
+		tableValues = append(tableValues, []string{envVar.Name, value, layer})
+	}
+	if err := table.Bulk(tableValues); err != nil {
+		return errors.WithStack(err)
 	}
+	if len(tableValues) == 0 {
+		fmt.Fprintln(w, "No environment variables currently defined.")
+	} else if err := table.Render(); err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// PrintVersionedEnvVars prints envVars as a table with the version and the
+// last modification time of each one. Variables without a version, such as
+// those inherited from other layers, show "-".
+func PrintVersionedEnvVars(
+	w io.Writer,
+	envID EnvID,
+	envVars []EnvVar,
+	versions map[string]Version,
+	expose bool,
+) error {
+	err := tux.WriteHeader(w, "Environment: %s\n", strings.ToLower(envID.EnvName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	table := tablewriter.NewWriter(w)
+	table.Header("Name", "Value", "Version", "Last Modified")
+	tableValues := [][]string{}
+	for _, envVar := range envVars {
+		value := "*****"
+		if expose {
+			value = printableValue(envVar.Value)
+		}
+		version, modified := "-", "-"
+		if v, ok := versions[envVar.Name]; ok {
+			version = v.ID
+			modified = v.LastModified.Format(time.RFC3339)
+		}
+		tableValues = append(tableValues, []string{envVar.Name, value, version, modified})
+	}
+	if err := table.Bulk(tableValues); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(tableValues) == 0 {
+		fmt.Fprintln(w, "No environment variables currently defined.")
+	} else if err := table.Render(); err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Fprintln(w)
+	return nil
 }
 
 func printTableFormat(w io.Writer, envID EnvID, envVars []EnvVar) error {
@@ -0,0 +1,122 @@
+package envsec
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/git"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// LocalLayer is the layer of the variables overridden in the local file.
+const LocalLayer = "local"
+
+// DefaultLocalFile is the default path of the local overrides file. It lives
+// in the .jetify directory, which is ignored by git.
+var DefaultLocalFile = filepath.Join(dirName, "local.env")
+
+// localFilePath returns the absolute path of the local overrides file, or an
+// empty string if local overrides are disabled.
+func (e *Envsec) localFilePath() string {
+	if e.LocalFile == "" || filepath.IsAbs(e.LocalFile) {
+		return e.LocalFile
+	}
+	return filepath.Join(e.WorkingDir, e.LocalFile)
+}
+
+// LocalOverrides returns the variables set in the local overrides file. A
+// missing file has no overrides.
+func (e *Envsec) LocalOverrides() (map[string]string, error) {
+	path := e.localFilePath()
+	if path == "" {
+		return map[string]string{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse local overrides in %s", path)
+	}
+	return values, nil
+}
+
+// SetLocal sets variables in the local overrides file, preserving the rest of
+// the file.
+func (e *Envsec) SetLocal(values map[string]string) error {
+	if err := ensureValidNames(lo.Keys(values)); err != nil {
+		return errors.WithStack(err)
+	}
+	path, existing, err := e.readLocalFile()
+	if err != nil {
+		return err
+	}
+	data, err := mergeDotEnv(existing, values)
+	if err != nil {
+		return err
+	}
+	if err := e.writeLocalFile(path, data); err != nil {
+		return err
+	}
+	names := lo.Keys(values)
+	sort.Strings(names)
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Set local %s %s in %q\n",
+		tux.Plural(names, "override", "overrides"),
+		strings.Join(tux.QuotedTerms(names), ", "),
+		path,
+	)
+}
+
+// DeleteLocal removes variables from the local overrides file.
+func (e *Envsec) DeleteLocal(names ...string) error {
+	path, existing, err := e.readLocalFile()
+	if err != nil {
+		return err
+	}
+	if err := e.writeLocalFile(path, removeDotEnv(existing, names)); err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Deleted local %s %s from %q\n",
+		tux.Plural(names, "override", "overrides"),
+		strings.Join(tux.QuotedTerms(names), ", "),
+		path,
+	)
+}
+
+func (e *Envsec) readLocalFile() (string, []byte, error) {
+	path := e.localFilePath()
+	if path == "" {
+		return "", nil, errors.New("no local overrides file is configured")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", nil, errors.WithStack(err)
+	}
+	return path, data, nil
+}
+
+func (e *Envsec) writeLocalFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return errors.WithStack(err)
+		}
+		// Keep overrides out of git when the .jetify directory is created here.
+		if filepath.Base(dir) == dirName {
+			if err := git.CreateGitIgnore(dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return writeFileAtomic(path, data, defaultFileMode)
+}
@@ -0,0 +1,304 @@
+package envsec
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// mergeSectionMarker precedes the variables that envsec appends to an existing
+// file because they were not present in it yet.
+const mergeSectionMarker = "# Added by envsec"
+
+var dotenvLineRegex = regexp.MustCompile(`^(\s*(?:export\s+)?)([A-Za-z_][A-Za-z0-9_.]*)(\s*=)(.*)$`)
+
+// mergeDotEnv updates the variables in an existing dotenv file that are also
+// present in values. Comments, blank lines and the order of existing
+// variables are preserved, and variables that are not present yet are appended
+// in a section marked with mergeSectionMarker. Replaced entries keep their
+// inline comments and line endings, and appended entries use CRLF line
+// endings if the file does.
+func mergeDotEnv(existing []byte, values map[string]string) ([]byte, error) {
+	lines := strings.SplitAfter(string(existing), "\n")
+	seen := map[string]bool{}
+	hasMarker := false
+	newline := "\n"
+	if bytes.Contains(existing, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	out := strings.Builder{}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == mergeSectionMarker {
+			hasMarker = true
+		}
+		match := dotenvLineRegex.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if match == nil {
+			out.WriteString(line)
+			continue
+		}
+
+		// A quoted value may span several lines. Consume all of them so that
+		// replacing the value does not leave stray lines behind.
+		end := dotEnvEntryEnd(lines, i, match[4])
+
+		name := match[2]
+		value, ok := values[name]
+		if !ok {
+			out.WriteString(strings.Join(lines[i:end+1], ""))
+			i = end
+			continue
+		}
+		seen[name] = true
+		encoded, err := encodeDotEnvValue(name, value)
+		if err != nil {
+			return nil, err
+		}
+		// The value, including the lines a quoted value spans.
+		rawValue := strings.TrimRight(match[4]+strings.Join(lines[i+1:end+1], ""), "\r\n")
+		out.WriteString(match[1] + encoded + inlineComment(rawValue) + lineEnding(lines[end]))
+		i = end
+	}
+
+	added := []string{}
+	for name := range values {
+		if !seen[name] {
+			added = append(added, name)
+		}
+	}
+	if len(added) == 0 {
+		return []byte(out.String()), nil
+	}
+	sort.Strings(added)
+
+	result := out.String()
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += newline
+	}
+	if !hasMarker {
+		if result != "" {
+			result += newline
+		}
+		result += mergeSectionMarker + newline
+	}
+	for _, name := range added {
+		encoded, err := encodeDotEnvValue(name, values[name])
+		if err != nil {
+			return nil, err
+		}
+		result += encoded + newline
+	}
+	return []byte(result), nil
+}
+
+// removeDotEnv removes the variables in names from an existing dotenv file,
+// preserving everything else in it.
+func removeDotEnv(existing []byte, names []string) []byte {
+	remove := lo.SliceToMap(names, func(name string) (string, bool) { return name, true })
+	lines := strings.SplitAfter(string(existing), "\n")
+	out := strings.Builder{}
+	for i := 0; i < len(lines); i++ {
+		match := dotenvLineRegex.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n"))
+		if match == nil {
+			out.WriteString(lines[i])
+			continue
+		}
+		end := dotEnvEntryEnd(lines, i, match[4])
+		if !remove[match[2]] {
+			out.WriteString(strings.Join(lines[i:end+1], ""))
+		}
+		i = end
+	}
+	return []byte(out.String())
+}
+
+// dotEnvEntryEnd returns the index of the last line of the entry that starts
+// at lines[start] with the given value, which may be a quoted value that
+// spans several lines.
+func dotEnvEntryEnd(lines []string, start int, value string) int {
+	end := start
+	if quote, ok := openQuote(value); ok {
+		rest := strings.TrimLeft(value, " \t")[1:]
+		for !hasClosingQuote(rest, quote) && end+1 < len(lines) {
+			end++
+			rest += lines[end]
+		}
+	}
+	return end
+}
+
+// encodeDotEnvValue returns a single NAME=VALUE entry, quoted and escaped the
+// same way as a fully downloaded dotenv file.
+func encodeDotEnvValue(name, value string) (string, error) {
+	encoded, err := godotenv.Marshal(map[string]string{name: value})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return encoded, nil
+}
+
+// openQuote reports whether value starts with a quote character and returns it.
+func openQuote(value string) (byte, bool) {
+	value = strings.TrimLeft(value, " \t")
+	if value == "" {
+		return 0, false
+	}
+	switch value[0] {
+	case '"', '\'', '`':
+		return value[0], true
+	}
+	return 0, false
+}
+
+// hasClosingQuote reports whether s contains an unescaped quote character.
+func hasClosingQuote(s string, quote byte) bool {
+	return closingQuoteIndex(s, quote) >= 0
+}
+
+// closingQuoteIndex returns the index of the first unescaped quote character
+// in s, or -1 if there is none.
+func closingQuoteIndex(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && quote == '"' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+// inlineComment returns the comment that follows value in a dotenv entry,
+// along with the whitespace before it, or "" if there is none.
+func inlineComment(value string) string {
+	if quote, ok := openQuote(value); ok {
+		rest := strings.TrimLeft(value, " \t")[1:]
+		end := closingQuoteIndex(rest, quote)
+		if end < 0 {
+			return ""
+		}
+		rest = rest[end+1:]
+		if strings.HasPrefix(strings.TrimLeft(rest, " \t"), "#") {
+			return rest
+		}
+		return ""
+	}
+	// Unquoted values end at a # that follows whitespace.
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return value[len(strings.TrimRight(value[:i], " \t")):]
+		}
+	}
+	return ""
+}
+
+// lineEnding returns the line ending line ends with, if any.
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+// mergeJSON updates the keys of an existing flat JSON object that are present
+// in values, preserving the order of existing keys and appending new ones.
+func mergeJSON(existing []byte, values map[string]string) ([]byte, error) {
+	type entry struct {
+		key   string
+		value json.RawMessage
+	}
+	entries := []entry{}
+
+	if len(bytes.TrimSpace(existing)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(existing))
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+			return nil, errors.New("existing file must contain a JSON object")
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			entries = append(entries, entry{key: tok.(string), value: raw})
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, e := range entries {
+		if v, ok := values[e.key]; ok {
+			seen[e.key] = true
+			raw, err := marshalJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			entries[i].value = raw
+		}
+	}
+	added := []string{}
+	for name := range values {
+		if !seen[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		raw, err := marshalJSONValue(values[name])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: name, value: raw})
+	}
+
+	compact := bytes.Buffer{}
+	compact.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			compact.WriteByte(',')
+		}
+		key, err := marshalJSONValue(e.key)
+		if err != nil {
+			return nil, err
+		}
+		compact.Write(key)
+		compact.WriteByte(':')
+		compact.Write(e.value)
+	}
+	compact.WriteByte('}')
+
+	result := bytes.Buffer{}
+	if err := json.Indent(&result, compact.Bytes(), "", "  "); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result.WriteByte('\n')
+	return result.Bytes(), nil
+}
+
+func marshalJSONValue(v string) (json.RawMessage, error) {
+	b := new(bytes.Buffer)
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
@@ -0,0 +1,177 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeDotEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		values   map[string]string
+		expected string
+	}{
+		{
+			name:     "updates managed keys and keeps comments",
+			existing: "# database\nDB_HOST=localhost\n\nLOCAL_ONLY=1\n",
+			values:   map[string]string{"DB_HOST": "db.internal"},
+			expected: "# database\nDB_HOST=\"db.internal\"\n\nLOCAL_ONLY=1\n",
+		},
+		{
+			name:     "keeps export prefix",
+			existing: "export API_KEY=old\n",
+			values:   map[string]string{"API_KEY": "new"},
+			expected: "export API_KEY=\"new\"\n",
+		},
+		{
+			name:     "replaces multi-line values",
+			existing: "CERT=\"line1\nline2\"\nAFTER=1\n",
+			values:   map[string]string{"CERT": "new"},
+			expected: "CERT=\"new\"\nAFTER=1\n",
+		},
+		{
+			name:     "appends new keys in a marked section",
+			existing: "A=1",
+			values:   map[string]string{"C": "c", "B": "b"},
+			expected: "A=1\n\n# Added by envsec\nB=\"b\"\nC=\"c\"\n",
+		},
+		{
+			name:     "keeps inline comments",
+			existing: "A=1 # local\nB=\"2\"  # quoted\nC=\"x\ny\" # multi\n",
+			values:   map[string]string{"A": "a", "B": "b", "C": "c"},
+			expected: "A=\"a\" # local\nB=\"b\"  # quoted\nC=\"c\" # multi\n",
+		},
+		{
+			name:     "keeps CRLF line endings",
+			existing: "A=1\r\nB=2\r\n",
+			values:   map[string]string{"A": "a", "C": "c"},
+			expected: "A=\"a\"\r\nB=2\r\n\r\n# Added by envsec\r\nC=\"c\"\r\n",
+		},
+		{
+			name:     "reuses an existing marked section",
+			existing: "A=1\n\n# Added by envsec\nB=\"b\"\n",
+			values:   map[string]string{"C": "c"},
+			expected: "A=1\n\n# Added by envsec\nB=\"b\"\nC=\"c\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := mergeDotEnv([]byte(test.existing), test.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != test.expected {
+				t.Errorf("Expected %q, but got %q", test.expected, string(result))
+			}
+		})
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	existing := `{"Z": "local", "A": "old"}`
+	expected := "{\n  \"Z\": \"local\",\n  \"A\": \"new\",\n  \"B\": \"<b>\"\n}\n"
+
+	result, err := mergeJSON([]byte(existing), map[string]string{"A": "new", "B": "<b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != expected {
+		t.Errorf("Expected %q, but got %q", expected, string(result))
+	}
+}
+
+func TestRemoveDotEnv(t *testing.T) {
+	existing := "# comment\nA=1\nB=\"multi\nline\"\nexport C=3\n"
+	expected := "# comment\nA=1\nexport C=3\n"
+	if result := string(removeDotEnv([]byte(existing), []string{"B"})); result != expected {
+		t.Errorf("Expected %q, but got %q", expected, result)
+	}
+}
+
+func TestDownloadMergeFileMode(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: dir,
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ".env")
+	tests := []struct {
+		existing os.FileMode
+		explicit os.FileMode
+		expected os.FileMode
+	}{
+		// Files that are readable by others are tightened.
+		{0o644, 0, 0o600},
+		{0o400, 0, 0o400},
+		{0o600, 0o644, 0o644},
+	}
+	for _, test := range tests {
+		if err := os.WriteFile(path, []byte("A=0\n"), test.existing); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, test.existing); err != nil {
+			t.Fatal(err)
+		}
+		err := e.Download(ctx, path, DownloadOptions{Merge: true, FileMode: test.explicit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != test.expected {
+			t.Errorf("Expected merging into a %v file with mode %v to write %v, but got %v",
+				test.existing, test.explicit, test.expected, info.Mode().Perm())
+		}
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDownloadMergeFollowsSymlinks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: dir,
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "shared.env")
+	if err := os.WriteFile(target, []byte("A=0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".env")
+	if err := os.Symlink("shared.env", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Download(ctx, link, DownloadOptions{Merge: true}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected .env to still be a symlink, but got %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "A=1\n" {
+		t.Errorf("Expected the target to be merged, but got %q", data)
+	}
+}
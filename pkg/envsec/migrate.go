@@ -0,0 +1,289 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	// From and To describe the source and target stores, e.g. "ssm://". They
+	// identify the migration when it is resumed.
+	From string
+	To   string
+	// Target is the store the variables are copied to. Store is the source.
+	Target Store
+	// Environments are the environments to migrate.
+	Environments []string
+	// DeleteSource deletes the variables from the source store once all of
+	// them have been copied and verified.
+	DeleteSource bool
+}
+
+// migrationFile records the progress of a migration, so that it can resume
+// where it stopped if it fails.
+var migrationFile = filepath.Join(dirName, "migration.json")
+
+type migrationState struct {
+	From         string                          `json:"from"`
+	To           string                          `json:"to"`
+	Environments map[string]*migratedEnvironment `json:"environments"`
+}
+
+type migratedEnvironment struct {
+	// Names are the variables that were copied.
+	Names         []string `json:"names"`
+	Verified      bool     `json:"verified"`
+	SourceDeleted bool     `json:"source_deleted,omitempty"`
+}
+
+// Migrate copies the variables of the environments from Store to
+// opts.Target and verifies them by reading them back. Progress is recorded in
+// the .jetify directory, so that running the same migration again after a
+// failure skips the environments that were already verified.
+func (e *Envsec) Migrate(ctx context.Context, opts MigrateOptions) error {
+	state, err := e.readMigrationState(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range opts.Environments {
+		if env := state.Environments[name]; env != nil && env.Verified {
+			err := tux.WriteHeader(e.Stderr,
+				"Environment %s was already copied, skipping it\n", name)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+		env, err := e.migrateEnvironment(ctx, name, opts.Target)
+		if err != nil {
+			return errors.Wrapf(err,
+				"failed to migrate environment %s. Run the same command again to resume", name)
+		}
+		state.Environments[name] = env
+		if err := e.writeMigrationState(state); err != nil {
+			return err
+		}
+	}
+
+	if opts.DeleteSource {
+		if err := e.deleteMigratedSource(ctx, state, opts.Environments); err != nil {
+			return err
+		}
+	}
+	if err := e.printMigrationReport(state, opts); err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(e.WorkingDir, migrationFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (e *Envsec) migrateEnvironment(
+	ctx context.Context,
+	name string,
+	target Store,
+) (*migratedEnvironment, error) {
+	envID := e.EnvID
+	envID.EnvName = name
+	envVars, err := e.Store.List(ctx, envID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read source")
+	}
+	// Names reserved for envsec, such as remote snapshots, belong to the
+	// source store and aren't copied.
+	values := map[string]string{}
+	for _, envVar := range envVars {
+		if !isReservedName(envVar.Name) {
+			values[envVar.Name] = envVar.Value
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(values) > 0 {
+		if err := target.SetAll(ctx, envID, values); err != nil {
+			return nil, errors.Wrap(err, "failed to write target")
+		}
+	}
+	copied, err := target.GetAll(ctx, envID, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read back target")
+	}
+	copiedValues := envVarsToMap(copied)
+	mismatched := []string{}
+	unset := []string{}
+	for _, name := range names {
+		value, ok := copiedValues[name]
+		switch {
+		case !ok && values[name] == "":
+			// Some stores, such as the Jetify API, don't keep empty values,
+			// which reads back as the variable not being set.
+			unset = append(unset, name)
+		case !ok || value != values[name]:
+			mismatched = append(mismatched, name)
+		}
+	}
+	if len(mismatched) > 0 {
+		return nil, errors.Errorf(
+			"verification failed, the target has different values for %s",
+			strings.Join(mismatched, ", "),
+		)
+	}
+	if len(unset) > 0 {
+		err := tux.WriteHeader(e.Stderr,
+			"[WARNING] The target store doesn't keep empty values. "+
+				"These variables are not set in environment %s: %s\n",
+			name,
+			strings.Join(unset, ", "),
+		)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	err = tux.WriteHeader(e.Stderr,
+		"Copied and verified %d %s in environment: %s\n",
+		len(names),
+		tux.Plural(names, "variable", "variables"),
+		name,
+	)
+	return &migratedEnvironment{Names: names, Verified: true}, errors.WithStack(err)
+}
+
+// deleteMigratedSource deletes the copied variables from the source store.
+// It only runs once every environment has been verified.
+func (e *Envsec) deleteMigratedSource(
+	ctx context.Context,
+	state *migrationState,
+	names []string,
+) error {
+	total := 0
+	for _, name := range names {
+		if !state.Environments[name].SourceDeleted {
+			total += len(state.Environments[name].Names)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	for _, name := range names {
+		env := state.Environments[name]
+		if env.SourceDeleted || len(env.Names) == 0 {
+			continue
+		}
+		if err := e.ensureWritable(name, "delete its migrated variables"); err != nil {
+			return err
+		}
+	}
+	ok, err := e.askYesNo(fmt.Sprintf(
+		"All variables were verified. Delete %d %s from the source store?",
+		total,
+		tux.Plural(make([]any, total), "variable", "variables"),
+	))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return tux.WriteHeader(e.Stderr, "Kept the variables in the source store\n")
+	}
+	for _, name := range names {
+		env := state.Environments[name]
+		if env.SourceDeleted {
+			continue
+		}
+		if len(env.Names) > 0 {
+			envID := e.EnvID
+			envID.EnvName = name
+			if err := e.Store.DeleteAll(ctx, envID, env.Names); err != nil {
+				return errors.Wrapf(err,
+					"failed to delete environment %s from the source. Run the same command again to resume",
+					name,
+				)
+			}
+		}
+		env.SourceDeleted = true
+		if err := e.writeMigrationState(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Envsec) printMigrationReport(state *migrationState, opts MigrateOptions) error {
+	err := tux.WriteHeader(e.Stderr, "[DONE] Migrated from %s to %s\n", opts.From, opts.To)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rows := [][]string{{"ENVIRONMENT", "VARIABLES", "SOURCE"}}
+	for _, name := range opts.Environments {
+		env := state.Environments[name]
+		source := "kept"
+		if env.SourceDeleted {
+			source = "deleted"
+		}
+		rows = append(rows, []string{name, strconv.Itoa(len(env.Names)), source})
+	}
+	return tux.FTable(e.Stderr, rows)
+}
+
+// readMigrationState reads the progress of a previous run of the same
+// migration, if any.
+func (e *Envsec) readMigrationState(opts MigrateOptions) (*migrationState, error) {
+	path := filepath.Join(e.WorkingDir, migrationFile)
+	state := &migrationState{
+		From:         opts.From,
+		To:           opts.To,
+		Environments: map[string]*migratedEnvironment{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	previous := &migrationState{}
+	if err := json.Unmarshal(data, previous); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	if previous.From != opts.From || previous.To != opts.To {
+		return nil, errors.Errorf(
+			"a migration from %s to %s is in progress. Finish it, or delete %s to start over",
+			previous.From,
+			previous.To,
+			path,
+		)
+	}
+	if previous.Environments == nil {
+		previous.Environments = map[string]*migratedEnvironment{}
+	}
+	err = tux.WriteHeader(e.Stderr, "Resuming the migration recorded in %s\n", path)
+	return previous, errors.WithStack(err)
+}
+
+func (e *Envsec) writeMigrationState(state *migrationState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	path := filepath.Join(e.WorkingDir, migrationFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileAtomic(path, data, defaultFileMode)
+}
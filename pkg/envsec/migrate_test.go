@@ -0,0 +1,120 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// envFailingStore is a memStore whose writes to one environment fail.
+type envFailingStore struct {
+	*memStore
+	failEnv string
+}
+
+func (f *envFailingStore) SetAll(ctx context.Context, envID EnvID, values map[string]string) error {
+	if envID.EnvName == f.failEnv {
+		return errors.New("set failed")
+	}
+	return f.memStore.SetAll(ctx, envID, values)
+}
+
+func TestMigrateResumesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	source := newMemStore()
+	dev := EnvID{ProjectID: "proj", EnvName: "dev"}
+	prod := EnvID{ProjectID: "proj", EnvName: "prod"}
+	source.env(dev)["A"] = "1"
+	source.env(prod)["B"] = "2"
+
+	e := &Envsec{
+		AssumeYes:  true,
+		Store:      source,
+		EnvID:      dev,
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	target := newMemStore()
+	opts := MigrateOptions{
+		From:         "ssm://",
+		To:           "jetify://",
+		Target:       &envFailingStore{memStore: target, failEnv: "prod"},
+		Environments: []string{"dev", "prod"},
+		DeleteSource: true,
+	}
+	if err := e.Migrate(ctx, opts); err == nil {
+		t.Fatal("Expected the migration of prod to fail")
+	}
+	if target.env(dev)["A"] != "1" {
+		t.Errorf("Expected dev to be copied before prod failed, but got %v", target.env(dev))
+	}
+	if len(source.env(dev)) != 1 {
+		t.Error("Expected the source to be kept after a failure")
+	}
+
+	// Resuming skips dev, which was already verified.
+	source.env(dev)["A"] = "changed"
+	opts.Target = target
+	if err := e.Migrate(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if target.env(dev)["A"] != "1" || target.env(prod)["B"] != "2" {
+		t.Errorf("Expected the target to have all variables, but got %v", target.envs)
+	}
+	if len(source.env(dev)) != 0 || len(source.env(prod)) != 0 {
+		t.Errorf("Expected the source to be deleted, but got %v", source.envs)
+	}
+	if _, err := os.Stat(filepath.Join(e.WorkingDir, migrationFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the migration state to be removed, but got %v", err)
+	}
+}
+
+// noEmptyValuesStore is a memStore that drops empty values, like the Jetify
+// API.
+type noEmptyValuesStore struct {
+	*memStore
+}
+
+func (s noEmptyValuesStore) SetAll(ctx context.Context, envID EnvID, values map[string]string) error {
+	for name, value := range values {
+		if value != "" {
+			s.env(envID)[name] = value
+		}
+	}
+	return nil
+}
+
+func TestMigrateSkipsReservedNamesAndEmptyValues(t *testing.T) {
+	ctx := context.Background()
+	source := newMemStore()
+	dev := EnvID{ProjectID: "proj", EnvName: "dev"}
+	source.env(dev)["A"] = "1"
+	source.env(dev)["EMPTY"] = ""
+	source.env(dev)[snapshotPrefix+"X"] = "snapshot"
+
+	e := &Envsec{
+		Store:      source,
+		EnvID:      dev,
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	target := newMemStore()
+	err := e.Migrate(ctx, MigrateOptions{
+		From:         "ssm://",
+		To:           "jetify://",
+		Target:       noEmptyValuesStore{target},
+		Environments: []string{"dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1"}
+	if !reflect.DeepEqual(target.env(dev), expected) {
+		t.Errorf("Expected %v, but got %v", expected, target.env(dev))
+	}
+}
@@ -0,0 +1,334 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// MoveOptions configures Move.
+type MoveOptions struct {
+	// AllEnvironments moves the variable in every environment of the project
+	// it is set in, instead of only in the current one.
+	AllEnvironments bool
+	// ToProject moves the variable to the same environments of another
+	// project of the organization.
+	ToProject string
+	// Force overwrites the new name if it is already set to another value.
+	Force bool
+}
+
+// MoveError is returned by Move when it fails partway. It records the state
+// each environment was left in, so that the move can be finished by running
+// it again.
+type MoveError struct {
+	OldName string
+	NewName string
+	// Environment is the environment the move failed in.
+	Environment string
+	// Copied reports whether NewName was set in Environment before OldName
+	// failed to be deleted, which leaves the variable set under both names.
+	Copied bool
+	// Moved are the environments the variable was moved in before the
+	// failure.
+	Moved []string
+	// Pending are the environments that weren't changed yet.
+	Pending []string
+	Err     error
+}
+
+func (e *MoveError) Error() string {
+	msg := strings.Builder{}
+	fmt.Fprintf(&msg, "failed to move %s to %s in environment %s: %v",
+		e.OldName, e.NewName, e.Environment, e.Err)
+	if e.Copied {
+		fmt.Fprintf(&msg, ". %s was copied to %s, but not deleted", e.OldName, e.NewName)
+	} else {
+		fmt.Fprintf(&msg, ". %s wasn't changed", e.Environment)
+	}
+	if len(e.Moved) > 0 {
+		fmt.Fprintf(&msg, ". Already moved in: %s", strings.Join(e.Moved, ", "))
+	}
+	if len(e.Pending) > 0 {
+		fmt.Fprintf(&msg, ". Not moved yet in: %s", strings.Join(e.Pending, ", "))
+	}
+	fmt.Fprintf(&msg, ". The progress is recorded in %s. Run the same command again to finish the move",
+		moveFile)
+	return msg.String()
+}
+
+func (e *MoveError) Unwrap() error {
+	return e.Err
+}
+
+// moveFile records the environments a failed move was completed in, so that
+// running it again can tell them apart from environments where the new name
+// was set all along.
+var moveFile = filepath.Join(dirName, "move.json")
+
+type moveState struct {
+	OldName   string   `json:"old_name"`
+	NewName   string   `json:"new_name"`
+	ToProject string   `json:"to_project"`
+	Moved     []string `json:"moved"`
+}
+
+// plannedMove is the move of a variable in one environment.
+type plannedMove struct {
+	from, to EnvID
+	value    string
+	// copied is set when the new name already holds the value, e.g. because
+	// a previous move failed before deleting the old name.
+	copied bool
+	// replaced holds the value of the new name that --force overwrites, if
+	// any, so that a transactional move can restore it.
+	replaced *string
+}
+
+// Move renames the variable oldName to newName, optionally moving it to
+// another project. Stores only hold the value of a variable, so that is what
+// is copied to the new name before the old name is deleted. A failure can
+// leave the variable under both names, which is reported in a MoveError and
+// recorded in the .jetify directory, so that running the same move again
+// finishes it. No store can set one variable and delete another atomically,
+// so if Transactional is set, a failure to delete the old name undoes
+// setting the new name instead.
+func (e *Envsec) Move(ctx context.Context, oldName, newName string, opts MoveOptions) error {
+	if isReservedName(oldName) {
+		return errors.Errorf("cannot move %s: the %s prefix is reserved", oldName, reservedPrefix)
+	}
+	if err := ensureValidNames([]string{newName}); err != nil {
+		return errors.WithStack(err)
+	}
+	toProject := opts.ToProject
+	if toProject == "" {
+		toProject = e.EnvID.ProjectID
+	}
+	if oldName == newName && toProject == e.EnvID.ProjectID {
+		return errors.Errorf("%s is already named %s", oldName, newName)
+	}
+	state, err := e.readMoveState(oldName, newName, toProject)
+	if err != nil {
+		return err
+	}
+
+	envNames := []string{e.EnvID.EnvName}
+	if opts.AllEnvironments {
+		var err error
+		if envNames, err = e.AllEnvironmentNames(ctx); err != nil {
+			return err
+		}
+	}
+
+	moves := []plannedMove{}
+	for _, envName := range envNames {
+		if lo.Contains(state.Moved, envName) {
+			continue
+		}
+		move, err := e.planMove(ctx, envName, toProject, oldName, newName, opts.Force)
+		if err != nil {
+			return err
+		}
+		if move != nil {
+			moves = append(moves, *move)
+		}
+	}
+	if len(moves) == 0 && len(state.Moved) == 0 {
+		if opts.AllEnvironments {
+			return errors.Errorf("%s is not set in any environment", oldName)
+		}
+		return errors.Errorf("%s is not set in environment %s", oldName, e.EnvID.EnvName)
+	}
+	for _, move := range moves {
+		action := fmt.Sprintf("move %s to %s", oldName, newName)
+		if err := e.ensureWritable(move.from.EnvName, action); err != nil {
+			return err
+		}
+	}
+	if toProject != e.EnvID.ProjectID {
+		if err := e.confirmOtherProject(toProject, newName, moves); err != nil {
+			return err
+		}
+	}
+
+	for i, move := range moves {
+		copied, err := e.moveInEnvironment(ctx, move, oldName, newName)
+		if err != nil {
+			moveErr := &MoveError{
+				OldName:     oldName,
+				NewName:     newName,
+				Environment: move.from.EnvName,
+				Copied:      copied,
+				Moved:       append([]string{}, state.Moved...),
+				Pending:     []string{},
+				Err:         err,
+			}
+			for _, m := range moves[i+1:] {
+				moveErr.Pending = append(moveErr.Pending, m.from.EnvName)
+			}
+			if err := e.writeMoveState(state); err != nil {
+				return errors.Wrapf(err, "failed to record the progress of the move after: %v", moveErr)
+			}
+			return moveErr
+		}
+		state.Moved = append(state.Moved, move.from.EnvName)
+	}
+	err = os.Remove(filepath.Join(e.WorkingDir, moveFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+
+	moved := state.Moved
+	sort.Strings(moved)
+	destination := ""
+	if toProject != e.EnvID.ProjectID {
+		destination = " of project " + toProject
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Moved environment variable '%s' to '%s'%s in %s: %s\n",
+		oldName,
+		newName,
+		destination,
+		tux.Plural(moved, "environment", "environments"),
+		strings.Join(moved, ", "),
+	)
+}
+
+// confirmOtherProject asks the user to confirm a move to another project. The
+// environment settings of that project are checked in with it, so whether its
+// environments are protected or frozen can't be told from here.
+func (e *Envsec) confirmOtherProject(toProject, newName string, moves []plannedMove) error {
+	envNames := lo.Map(moves, func(m plannedMove, _ int) string { return m.to.EnvName })
+	ok, err := e.askYesNo(fmt.Sprintf(
+		"Set %s in %s %s of project %s? Its protected and frozen environments can't be checked from here",
+		newName,
+		tux.Plural(envNames, "environment", "environments"),
+		strings.Join(envNames, ", "),
+		toProject,
+	))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("move aborted")
+	}
+	return nil
+}
+
+// planMove checks that oldName can be moved in environment envName. It
+// returns nil if oldName isn't set in envName.
+func (e *Envsec) planMove(
+	ctx context.Context,
+	envName, toProject, oldName, newName string,
+	force bool,
+) (*plannedMove, error) {
+	move := &plannedMove{from: e.EnvID, to: e.EnvID}
+	move.from.EnvName, move.to.EnvName = envName, envName
+	move.to.ProjectID = toProject
+
+	old, err := e.Store.GetAll(ctx, move.from, []string{oldName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read environment %s", envName)
+	}
+	if len(old) == 0 {
+		return nil, nil
+	}
+	existing, err := e.Store.GetAll(ctx, move.to, []string{newName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read environment %s", envName)
+	}
+	move.value = old[0].Value
+	if len(existing) > 0 {
+		move.copied = existing[0].Value == move.value
+		if !move.copied && !force {
+			return nil, errors.Errorf(
+				"%s is already set in environment %s. Use --force to overwrite it",
+				newName,
+				envName,
+			)
+		}
+		move.replaced = &existing[0].Value
+	}
+	return move, nil
+}
+
+// moveInEnvironment carries out move, and reports whether newName was set
+// if it fails.
+func (e *Envsec) moveInEnvironment(
+	ctx context.Context,
+	move plannedMove,
+	oldName, newName string,
+) (bool, error) {
+	if !move.copied {
+		if err := e.Store.Set(ctx, move.to, newName, move.value); err != nil {
+			return false, errors.Wrapf(err, "failed to set %s", newName)
+		}
+	}
+	err := e.Store.Delete(ctx, move.from, oldName)
+	if err == nil {
+		return false, nil
+	}
+	err = errors.Wrapf(err, "failed to delete %s", oldName)
+	if !e.Transactional || move.copied {
+		return true, err
+	}
+
+	// Undo setting newName, so that the environment is left as it was.
+	var undoErr error
+	if move.replaced != nil {
+		undoErr = e.Store.Set(ctx, move.to, newName, *move.replaced)
+	} else {
+		undoErr = e.Store.Delete(ctx, move.to, newName)
+	}
+	if undoErr != nil {
+		return true, &RollbackError{Err: err, Failed: map[string]error{newName: undoErr}}
+	}
+	return false, &RollbackError{Err: err, RolledBack: []string{newName}}
+}
+
+// readMoveState reads the progress of a previous run of the same move, if
+// any.
+func (e *Envsec) readMoveState(oldName, newName, toProject string) (*moveState, error) {
+	path := filepath.Join(e.WorkingDir, moveFile)
+	state := &moveState{OldName: oldName, NewName: newName, ToProject: toProject}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	previous := &moveState{}
+	if err := json.Unmarshal(data, previous); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	if previous.OldName != oldName || previous.NewName != newName || previous.ToProject != toProject {
+		return nil, errors.Errorf(
+			"a move of %s to %s is in progress. Finish it, or delete %s to start over",
+			previous.OldName,
+			previous.NewName,
+			path,
+		)
+	}
+	err = tux.WriteHeader(e.Stderr, "Resuming the move recorded in %s\n", path)
+	return previous, errors.WithStack(err)
+}
+
+func (e *Envsec) writeMoveState(state *moveState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	path := filepath.Join(e.WorkingDir, moveFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileAtomic(path, data, defaultFileMode)
+}
@@ -0,0 +1,151 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// deleteFailingStore is a memStore whose deletes fail in one environment,
+// optionally only for one variable.
+type deleteFailingStore struct {
+	*memStore
+	envName string
+	name    string
+}
+
+func (s *deleteFailingStore) Delete(ctx context.Context, envID EnvID, name string) error {
+	if envID.EnvName == s.envName && (s.name == "" || name == s.name) {
+		return errors.New("permission denied")
+	}
+	return s.memStore.Delete(ctx, envID, name)
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+	store := &deleteFailingStore{memStore: newMemStore(), envName: "prod"}
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	err := e.writeConfigForTest(map[string]EnvironmentConfig{
+		"dev": {}, "preview": {}, "prod": {}, "test": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID := func(project, envName string) EnvID {
+		return EnvID{ProjectID: project, EnvName: envName}
+	}
+	store.env(envID("proj", "dev"))["OLD"] = "d"
+	store.env(envID("proj", "preview"))["OLD"] = "p"
+	store.env(envID("proj", "prod"))["OLD"] = "x"
+	store.env(envID("proj", "test"))["OLD"] = "t"
+
+	store.env(envID("proj", "dev"))["NEW"] = "taken"
+	if err := e.Move(ctx, "OLD", "NEW", MoveOptions{}); err == nil {
+		t.Error("Expected moving to a name that is already set to fail")
+	}
+	delete(store.env(envID("proj", "dev")), "NEW")
+
+	err = e.Move(ctx, "OLD", "NEW", MoveOptions{AllEnvironments: true})
+	var moveErr *MoveError
+	if !errors.As(err, &moveErr) {
+		t.Fatalf("Expected a MoveError, but got %v", err)
+	}
+	if moveErr.Environment != "prod" || !moveErr.Copied ||
+		!reflect.DeepEqual(moveErr.Moved, []string{"dev", "preview"}) ||
+		!reflect.DeepEqual(moveErr.Pending, []string{"test"}) {
+		t.Errorf("Unexpected record of the failed move: %v", moveErr)
+	}
+	expected := map[string]string{"OLD": "x", "NEW": "x"}
+	if prod := store.env(envID("proj", "prod")); !reflect.DeepEqual(prod, expected) {
+		t.Errorf("Expected prod to have %v, but got %v", expected, prod)
+	}
+
+	// Running the move again finishes it.
+	store.envName = ""
+	if err := e.Move(ctx, "OLD", "NEW", MoveOptions{AllEnvironments: true}); err != nil {
+		t.Fatal(err)
+	}
+	for envName, value := range map[string]string{"dev": "d", "preview": "p", "prod": "x", "test": "t"} {
+		expected := map[string]string{"NEW": value}
+		if env := store.env(envID("proj", envName)); !reflect.DeepEqual(env, expected) {
+			t.Errorf("Expected %s to have %v, but got %v", envName, expected, env)
+		}
+	}
+
+	// The settings of the other project can't be checked, so the move must
+	// be confirmed.
+	if err := e.Move(ctx, "NEW", "NEW", MoveOptions{ToProject: "other"}); err == nil {
+		t.Error("Expected a move to another project to require confirmation")
+	}
+	e.Stdin = strings.NewReader("y\n")
+	if err := e.Move(ctx, "NEW", "NEW", MoveOptions{ToProject: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	e.Stdin = nil
+	if value := store.env(envID("other", "dev"))["NEW"]; value != "d" {
+		t.Errorf("Expected NEW to be moved to the other project, but got %q", value)
+	}
+	if _, ok := store.env(envID("proj", "dev"))["NEW"]; ok {
+		t.Error("Expected NEW to be deleted from the original project")
+	}
+
+	// A variable that happens to be set under the new name isn't mistaken
+	// for one that was already moved.
+	store.env(envID("proj", "dev"))["EXISTING"] = "e"
+	for _, opts := range []MoveOptions{{}, {AllEnvironments: true}} {
+		if err := e.Move(ctx, "TYPO", "EXISTING", opts); err == nil {
+			t.Errorf("Expected moving a variable that isn't set to fail with %+v", opts)
+		}
+	}
+	if value := store.env(envID("proj", "dev"))["EXISTING"]; value != "e" {
+		t.Errorf("Expected EXISTING to be unchanged, but got %q", value)
+	}
+}
+
+func TestTransactionalMove(t *testing.T) {
+	ctx := context.Background()
+	store := &deleteFailingStore{memStore: newMemStore(), envName: "dev", name: "OLD"}
+	e := &Envsec{
+		Store:         store,
+		EnvID:         EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:        io.Discard,
+		WorkingDir:    t.TempDir(),
+		Transactional: true,
+	}
+	if err := e.writeConfigForTest(nil); err != nil {
+		t.Fatal(err)
+	}
+	dev := store.env(e.EnvID)
+
+	for _, existing := range []map[string]string{{}, {"NEW": "taken"}} {
+		for name, value := range existing {
+			dev[name] = value
+		}
+		dev["OLD"] = "v"
+		err := e.Move(ctx, "OLD", "NEW", MoveOptions{Force: true})
+		var moveErr *MoveError
+		var rollbackErr *RollbackError
+		if !errors.As(err, &moveErr) || !errors.As(err, &rollbackErr) {
+			t.Fatalf("Expected a rolled back MoveError, but got %v", err)
+		}
+		if moveErr.Copied || !reflect.DeepEqual(rollbackErr.RolledBack, []string{"NEW"}) {
+			t.Errorf("Expected NEW to be rolled back, but got %v", err)
+		}
+		expected := map[string]string{"OLD": "v"}
+		for name, value := range existing {
+			expected[name] = value
+		}
+		if !reflect.DeepEqual(dev, expected) {
+			t.Errorf("Expected dev to be left as %v, but got %v", expected, dev)
+		}
+	}
+}
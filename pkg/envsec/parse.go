@@ -0,0 +1,287 @@
+package envsec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSeparator joins the keys of nested values when they are flattened
+// into variable names.
+const defaultSeparator = "_"
+
+func decodeDotEnv(data []byte) (map[string]any, error) {
+	values, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result := map[string]any{}
+	for k, v := range values {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func decodeJSON(data []byte) (map[string]any, error) {
+	result := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as written instead of converting them to float64.
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return result, nil
+}
+
+func decodeYAML(data []byte) (map[string]any, error) {
+	result := map[string]any{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return result, nil
+}
+
+func decodeTOML(data []byte) (map[string]any, error) {
+	result := map[string]any{}
+	if err := toml.Unmarshal(data, &result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return result, nil
+}
+
+// decodeProperties parses a Java .properties file. Keys are returned as
+// written, the dots in them are turned into separators when flattening.
+func decodeProperties(data []byte) (map[string]any, error) {
+	result := map[string]any{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	logical := ""
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// An odd number of trailing backslashes continues the line.
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value := splitProperty(logical)
+		result[unescapeProperty(key)] = unescapeProperty(value)
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if logical != "" {
+		key, value := splitProperty(logical)
+		result[unescapeProperty(key)] = unescapeProperty(value)
+	}
+	return result, nil
+}
+
+// splitProperty splits a logical line at the first unescaped '=', ':' or
+// whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = rest[1:]
+			}
+			return line[:i], strings.TrimLeft(rest, " \t\f")
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			r, ok := parseUnicodeEscape(s[i+1:])
+			if !ok {
+				b.WriteByte('u')
+				continue
+			}
+			i += 4
+			// Characters outside the BMP are written as a surrogate pair.
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) {
+				if r2, ok := parseUnicodeEscape(s[i+3:]); ok {
+					r = utf16.DecodeRune(r, r2)
+					i += 6
+				}
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// parseUnicodeEscape parses the 4 hex digits that follow a \u escape.
+func parseUnicodeEscape(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	r, err := strconv.ParseUint(s[:4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(r), true
+}
+
+// flatten turns a decoded document into a flat map of variable names. Keys of
+// nested values and keys containing dots are joined with separator and upper
+// cased, so {"database": {"host": "x"}} becomes DATABASE_HOST=x. Top level
+// keys keep their case. Names are returned as they are, even if they contain
+// characters that names can't, so that they can all be reported together.
+func flatten(doc map[string]any, separator string) (map[string]string, error) {
+	if separator == "" {
+		separator = defaultSeparator
+	}
+	result := map[string]string{}
+	var walk func(path []string, v any) error
+	walk = func(path []string, v any) error {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if err := walk(append(path[:len(path):len(path)], strings.Split(k, ".")...), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		case []any:
+			for i, child := range v {
+				if err := walk(append(path[:len(path):len(path)], strconv.Itoa(i)), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		name := path[0]
+		if len(path) > 1 {
+			name = strings.ToUpper(strings.Join(path, separator))
+		}
+		value, err := scalarString(v)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value for %s", name)
+		}
+		if _, ok := result[name]; ok {
+			return errors.Errorf("more than one value maps to the name %s", name)
+		}
+		result[name] = value
+		return nil
+	}
+	// Walk keys in a stable order so that errors are deterministic.
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := walk(strings.Split(k, "."), doc[k]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func scalarString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int64, uint64:
+		return fmt.Sprint(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case fmt.Stringer:
+		// Dates and times in YAML and TOML.
+		return v.String(), nil
+	}
+	return "", errors.Errorf("unsupported value of type %T", v)
+}
+
+var (
+	tomlTableRegex = regexp.MustCompile(`^\[\[?[^\]]+\]\]?$`)
+	yamlKeyRegex   = regexp.MustCompile(`^[\w.-]+\s*:(\s|$)`)
+)
+
+// detectFormat returns the format of a file to upload, based on its
+// extension and falling back to its content.
+func detectFormat(name, path string, data []byte) (Format, error) {
+	if name != "" {
+		f, ok := LookupFormat(name)
+		if !ok || f.Decode == nil {
+			return Format{}, ValidateUploadFormat(name)
+		}
+		return f, nil
+	}
+	if f, ok := formatForExt(path); ok && f.Decode != nil {
+		return f, nil
+	}
+
+	detected := "dotenv"
+	first := true
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		switch {
+		case first && line[0] == '{':
+			detected = "json"
+		case first && (line == "---" || strings.HasPrefix(line, "- ") ||
+			yamlKeyRegex.MatchString(line)):
+			detected = "yaml"
+		case tomlTableRegex.MatchString(line):
+			// Tables may come after top level keys, which look like dotenv.
+			detected = "toml"
+		}
+		if detected != "dotenv" {
+			break
+		}
+		first = false
+	}
+	f, _ := LookupFormat(detected)
+	return f, nil
+}
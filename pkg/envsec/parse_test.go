@@ -0,0 +1,143 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadNested(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{"json", "json", `{"database": {"host": "db", "port": 5432}, "DEBUG": true}`},
+		{"yaml", "yaml", "database:\n  host: db\n  port: 5432\nDEBUG: true\n"},
+		{"toml", "toml", "DEBUG = true\n[database]\nhost = \"db\"\nport = 5432\n"},
+		{"properties", "properties", "database.host=db\ndatabase.port : 5432\nDEBUG true\n"},
+	}
+	expected := map[string]string{
+		"DATABASE_HOST": "db",
+		"DATABASE_PORT": "5432",
+		"DEBUG":         "true",
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := detectFormat("", "file", []byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.format != "properties" && f.Name != test.format {
+				t.Errorf("Expected format %s, but got %s", test.format, f.Name)
+			}
+			f, _ = LookupFormat(test.format)
+			doc, err := f.Decode([]byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := flatten(doc, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Expected %v, but got %v", expected, result)
+			}
+		})
+	}
+}
+
+func TestFlattenSeparatorAndLists(t *testing.T) {
+	doc := map[string]any{"hosts": []any{"a", "b"}, "flat": "x"}
+	result, err := flatten(doc, "__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"HOSTS__0": "a", "HOSTS__1": "b", "flat": "x"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, but got %v", expected, result)
+	}
+}
+
+func TestUploadReportsInvalidNames(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	path := filepath.Join(e.WorkingDir, "config.json")
+	data := `{"API-KEY": "a", "tls.crt": "b", "db": {"host-name": "c"}, "1st": "d", "OK": "e"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := e.Upload(ctx, []string{path}, UploadOptions{})
+	if err == nil {
+		t.Fatal("Expected invalid names to be rejected")
+	}
+	for _, name := range []string{"API-KEY", "DB_HOST-NAME", "1st"} {
+		if !strings.Contains(err.Error(), "name "+name+" must match") {
+			t.Errorf("Expected %s to be reported, but got %v", name, err)
+		}
+	}
+	if len(store.env(e.EnvID)) != 0 {
+		t.Errorf("Expected nothing to be uploaded, but got %v", store.env(e.EnvID))
+	}
+
+	if err := e.Upload(ctx, []string{path}, UploadOptions{NormalizeNames: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"API_KEY":      "a",
+		"TLS_CRT":      "b",
+		"DB_HOST_NAME": "c",
+		"_1st":         "d",
+		"OK":           "e",
+	}
+	if !reflect.DeepEqual(store.env(e.EnvID), expected) {
+		t.Errorf("Expected %v, but got %v", expected, store.env(e.EnvID))
+	}
+}
+
+func TestDecodeProperties(t *testing.T) {
+	input := "# comment\nkey\\ with\\ spaces = a \\\n    b\nunicode=\\u00e9\\ud83d\\ude00\n"
+	doc, err := decodeProperties([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"key with spaces": "a b", "unicode": "é😀"}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Expected %v, but got %v", expected, doc)
+	}
+}
+
+func TestEnsureValidNamesReportsAll(t *testing.T) {
+	err := ensureValidNames([]string{"1BAD", "OK", "JETPACK_X"})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	expected := "2 errors occurred:\n" +
+		"\t* name 1BAD must match the regular expression: ^[a-zA-Z_][a-zA-Z0-9_]*$ \n" +
+		"\t* name JETPACK_X cannot start with JETPACK_ (or lowercase)\n\n"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, but got %q", expected, err.Error())
+	}
+}
+
+func TestEnsureValidNamesAnchored(t *testing.T) {
+	names := []string{"OK", "API-KEY", "tls.crt"}
+	if err := ensureValidNames(names); err == nil {
+		t.Error("Expected names with invalid characters after a valid prefix to be rejected")
+	}
+	if !reflect.DeepEqual(names, []string{"OK", "API-KEY", "tls.crt"}) {
+		t.Errorf("Expected the names to be left in order, but got %v", names)
+	}
+}
@@ -0,0 +1,157 @@
+package envsec
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// ErrFrozen is returned when writing to a frozen environment.
+var ErrFrozen = errors.New("environment is frozen")
+
+// ensureWritable checks that the variables of environment envName can be
+// changed. Frozen environments can't be changed at all, and changes to
+// protected environments must be confirmed by typing the environment name,
+// unless AssumeYes is set. action describes the change, e.g. "delete API_KEY".
+//
+// Both settings are kept with the environments in environmentsName, which is
+// checked in, so every checkout of the project applies them. They are a
+// safeguard against mistakes, not access control: the store doesn't enforce
+// them.
+func (e *Envsec) ensureWritable(envName, action string) error {
+	environments, err := e.Environments()
+	if err != nil {
+		return err
+	}
+	cfg := environments[envName]
+	if cfg.Frozen {
+		return errors.Wrapf(ErrFrozen,
+			"cannot %s in environment %s. Run `envsec env unfreeze %s` first",
+			action,
+			envName,
+			envName,
+		)
+	}
+	if cfg.Protected {
+		return e.confirm(envName, action)
+	}
+	return nil
+}
+
+// confirm asks the user to type the name of the environment to confirm
+// action. It fails without asking if there is no Stdin to read from.
+func (e *Envsec) confirm(envName, action string) error {
+	if e.AssumeYes {
+		return nil
+	}
+	if e.Stdin == nil {
+		return errors.Errorf(
+			"environment %s is protected and confirmation is required to %s. "+
+				"Run the command interactively or pass --yes",
+			envName,
+			action,
+		)
+	}
+	err := tux.WriteHeader(e.Stderr,
+		"Environment %s is protected. Type %q to %s: ", envName, envName, action)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	answer, err := readLine(e.Stdin)
+	if err != nil {
+		return errors.Wrap(err, "failed to read confirmation")
+	}
+	if answer != envName {
+		return errors.Errorf("confirmation did not match environment name %s. Aborted", envName)
+	}
+	return nil
+}
+
+// ProtectEnvironment turns the protection of an environment on or off.
+// Removing the protection must itself be confirmed.
+func (e *Envsec) ProtectEnvironment(name string, protected bool) error {
+	verb := "Protected"
+	if !protected {
+		verb = "Unprotected"
+	}
+	return e.updateEnvironmentConfig(name, verb, func(cfg *EnvironmentConfig) error {
+		if cfg.Protected && !protected {
+			if err := e.confirm(name, "remove its protection"); err != nil {
+				return err
+			}
+		}
+		cfg.Protected = protected
+		return nil
+	})
+}
+
+// FreezeEnvironment makes an environment read-only, or writable again. Unfreezing a protected environment must be confirmed.
+func (e *Envsec) FreezeEnvironment(name string, frozen bool) error {
+	verb := "Froze"
+	if !frozen {
+		verb = "Unfroze"
+	}
+	return e.updateEnvironmentConfig(name, verb, func(cfg *EnvironmentConfig) error {
+		if cfg.Frozen && !frozen && cfg.Protected {
+			if err := e.confirm(name, "unfreeze it"); err != nil {
+				return err
+			}
+		}
+		cfg.Frozen = frozen
+		return nil
+	})
+}
+
+func (e *Envsec) updateEnvironmentConfig(
+	name string,
+	verb string,
+	update func(cfg *EnvironmentConfig) error,
+) error {
+	err := e.updateEnvironments(func(environments map[string]EnvironmentConfig) error {
+		cfg, ok := environments[name]
+		if !ok {
+			return errors.Errorf("environment %q does not exist", name)
+		}
+		if err := update(&cfg); err != nil {
+			return err
+		}
+		environments[name] = cfg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] %s environment: %s. Check in %s to apply it to every checkout\n",
+		verb,
+		name,
+		filepath.Join(dirName, environmentsName),
+	)
+}
+
+// readLine reads a line from r and trims surrounding whitespace. It reads a
+// byte at a time so that nothing after the line is consumed, which lets
+// several prompts share the same reader.
+func readLine(r io.Reader) (string, error) {
+	line := strings.Builder{}
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line.WriteByte(b[0])
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && line.Len() > 0 {
+				break
+			}
+			return "", errors.WithStack(err)
+		}
+	}
+	return strings.TrimSpace(line.String()), nil
+}
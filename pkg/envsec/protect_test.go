@@ -0,0 +1,85 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestProtectedEnvironments(t *testing.T) {
+	ctx := context.Background()
+	e := &Envsec{
+		Store:      newMemStore(),
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "prod"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.writeConfigForTest(map[string]EnvironmentConfig{
+		"prod": {Protected: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Set(ctx, "A", "1"); err == nil {
+		t.Error("Expected an error without a way to confirm")
+	}
+	e.Stdin = strings.NewReader("dev\n")
+	if err := e.Set(ctx, "A", "1"); err == nil {
+		t.Error("Expected an error when the confirmation doesn't match")
+	}
+	e.Stdin = strings.NewReader("prod\n")
+	if err := e.Set(ctx, "A", "1"); err != nil {
+		t.Errorf("Expected a confirmed change to succeed, but got %v", err)
+	}
+
+	e.Stdin = nil
+	e.AssumeYes = true
+	if err := e.FreezeEnvironment("prod", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteAll(ctx, "A"); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Expected ErrFrozen, but got %v", err)
+	}
+	if err := e.FreezeEnvironment("prod", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteAll(ctx, "A"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFrozenEnvironmentsRejectAllWrites(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	e := &Envsec{
+		Store:      store,
+		EnvID:      EnvID{ProjectID: "proj", EnvName: "prod"},
+		Stderr:     io.Discard,
+		WorkingDir: t.TempDir(),
+		AssumeYes:  true,
+	}
+	e.IdentityFile = filepath.Join(e.WorkingDir, "key.txt")
+	if err := e.writeConfigForTest(map[string]EnvironmentConfig{
+		"prod": {Frozen: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	store.env(e.EnvID)["A"] = "1"
+
+	if _, err := e.CreateSnapshot(ctx, "", SnapshotStorageRemote); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Expected ErrFrozen saving a remote snapshot, but got %v", err)
+	}
+	state := &migrationState{Environments: map[string]*migratedEnvironment{
+		"prod": {Names: []string{"A"}, Verified: true},
+	}}
+	if err := e.deleteMigratedSource(ctx, state, []string{"prod"}); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Expected ErrFrozen deleting migrated variables, but got %v", err)
+	}
+	if value := store.env(e.EnvID)["A"]; value != "1" {
+		t.Errorf("Expected A to be kept, but got %q", value)
+	}
+}
@@ -0,0 +1,236 @@
+package envsec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/tux"
+	"go.jetify.com/pkg/api"
+	"go.jetify.com/pkg/ids"
+)
+
+// ReferenceScheme is the URI scheme of secret references.
+const ReferenceScheme = "envsec"
+
+// A Reference points at a stored variable instead of containing its value. It
+// is written as envsec://<project>/<environment>/<NAME>, where project is a
+// project ID or name. An empty project, as in envsec:///prod/NAME, refers to
+// the current project.
+type Reference struct {
+	Project     string
+	Environment string
+	Name        string
+}
+
+var referenceRegex = regexp.MustCompile(
+	ReferenceScheme + `://([A-Za-z0-9_.-]*)/([A-Za-z0-9_-]+)/([A-Za-z_][A-Za-z0-9_]*)`,
+)
+
+func ParseReference(s string) (Reference, error) {
+	match := referenceRegex.FindStringSubmatch(s)
+	if match == nil || match[0] != s {
+		return Reference{}, errors.Errorf(
+			"invalid reference %q. Must be of the form %s://<project>/<environment>/<NAME>",
+			s,
+			ReferenceScheme,
+		)
+	}
+	return Reference{Project: match[1], Environment: match[2], Name: match[3]}, nil
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s://%s/%s/%s", ReferenceScheme, r.Project, r.Environment, r.Name)
+}
+
+// ResolveReferences returns the values of the referenced variables. It issues
+// a single GetAll per project and environment, and fails if any of the
+// referenced variables does not exist.
+func (e *Envsec) ResolveReferences(
+	ctx context.Context,
+	refs []Reference,
+) (map[Reference]string, error) {
+	type group struct {
+		project, environment string
+	}
+	groups := map[group][]string{}
+	for _, ref := range refs {
+		g := group{ref.Project, ref.Environment}
+		groups[g] = append(groups[g], ref.Name)
+	}
+
+	result := map[Reference]string{}
+	missing := []string{}
+	for g, names := range groups {
+		names = lo.Uniq(names)
+		projectID, err := e.resolveProject(ctx, g.project)
+		if err != nil {
+			return nil, err
+		}
+		envID, err := NewEnvID(projectID, e.EnvID.OrgID, g.environment)
+		if err != nil {
+			return nil, err
+		}
+		envVars, err := e.Store.GetAll(ctx, envID, names)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		found := map[string]string{}
+		for _, envVar := range envVars {
+			found[envVar.Name] = envVar.Value
+		}
+		for _, name := range names {
+			ref := Reference{Project: g.project, Environment: g.environment, Name: name}
+			value, ok := found[name]
+			if !ok {
+				missing = append(missing, ref.String())
+				continue
+			}
+			result[ref] = value
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, errors.Errorf(
+			"could not resolve %s %s",
+			tux.Plural(missing, "reference", "references"),
+			strings.Join(missing, ", "),
+		)
+	}
+	return result, nil
+}
+
+// resolveProject returns the project ID for the project part of a reference.
+func (e *Envsec) resolveProject(ctx context.Context, project string) (string, error) {
+	if project == "" {
+		return e.EnvID.ProjectID, nil
+	}
+	if _, err := ids.ParseProjectID(project); err == nil {
+		return project, nil
+	}
+
+	orgID, err := ids.ParseOrgID(e.EnvID.OrgID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	authClient, err := e.AuthClient()
+	if err != nil {
+		return "", err
+	}
+	tok, err := authClient.LoginFlowIfNeededForOrg(ctx, orgID.String())
+	if err != nil {
+		return "", err
+	}
+	projects, err := api.NewClient(ctx, e.APIHost, tok).ListProjects(ctx, orgID)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range projects {
+		if p.GetName() == project {
+			return p.GetId(), nil
+		}
+	}
+	return "", errors.Errorf("could not find project %q", project)
+}
+
+// InjectReferences replaces every reference in text with the referenced value.
+func (e *Envsec) InjectReferences(ctx context.Context, text string) (string, error) {
+	result, err := e.injectReferences(ctx, []string{text})
+	if err != nil {
+		return "", err
+	}
+	return result[0], nil
+}
+
+// injectReferences replaces the references in all texts, resolving them
+// together so that each environment is only fetched once.
+func (e *Envsec) injectReferences(ctx context.Context, texts []string) ([]string, error) {
+	refs := []Reference{}
+	for _, text := range texts {
+		for _, m := range referenceRegex.FindAllString(text, -1) {
+			ref, err := ParseReference(m)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		return texts, nil
+	}
+	values, err := e.ResolveReferences(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(texts))
+	for i, text := range texts {
+		result[i] = referenceRegex.ReplaceAllStringFunc(text, func(m string) string {
+			ref, _ := ParseReference(m)
+			return values[ref]
+		})
+	}
+	return result, nil
+}
+
+type InjectOptions struct {
+	// Output is the path of the resulting file. If empty or "-", the result is
+	// written to stdout.
+	Output string
+	// FileMode is the permission of the resulting file. Defaults to 0600.
+	FileMode os.FileMode
+}
+
+// Inject reads the file at inputPath, replaces every reference in it and
+// writes the result.
+func (e *Envsec) Inject(ctx context.Context, inputPath string, opts InjectOptions) error {
+	if !filepath.IsAbs(inputPath) {
+		inputPath = filepath.Join(e.WorkingDir, inputPath)
+	}
+	input, err := os.ReadFile(inputPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	output, err := e.InjectReferences(ctx, string(input))
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "" || opts.Output == "-" {
+		_, err = e.stdout().Write([]byte(output))
+		return errors.WithStack(err)
+	}
+	path := opts.Output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.WorkingDir, path)
+	}
+	if err := writeFileAtomic(path, []byte(output), opts.FileMode); err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Injected secrets from %q into %q\n", inputPath, path)
+}
+
+// ResolveEnviron replaces the references found in the values of environ, a
+// list of NAME=VALUE entries as returned by os.Environ.
+func (e *Envsec) ResolveEnviron(ctx context.Context, environ []string) ([]string, error) {
+	names := make([]string, len(environ))
+	values := make([]string, len(environ))
+	for i, kv := range environ {
+		names[i], values[i], _ = strings.Cut(kv, "=")
+	}
+	values, err := e.injectReferences(ctx, values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve references in environment variables")
+	}
+	resolved := make([]string, len(environ))
+	for i := range environ {
+		resolved[i] = names[i] + "=" + values[i]
+	}
+	return resolved, nil
+}
@@ -0,0 +1,58 @@
+package envsec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("envsec://my-project/prod/DATABASE_URL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Reference{Project: "my-project", Environment: "prod", Name: "DATABASE_URL"}
+	if ref != expected {
+		t.Errorf("Expected %v, but got %v", expected, ref)
+	}
+	if ref.String() != "envsec://my-project/prod/DATABASE_URL" {
+		t.Errorf("Unexpected string %s", ref.String())
+	}
+
+	for _, invalid := range []string{"envsec://p/prod", "http://p/prod/X", "envsec://p/prod/1X"} {
+		if _, err := ParseReference(invalid); err == nil {
+			t.Errorf("Expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestInjectReferences(t *testing.T) {
+	store := newMemStore()
+	prod := EnvID{ProjectID: "proj_1", EnvName: "prod"}
+	store.env(prod)["DB"] = "postgres://db"
+	store.env(EnvID{ProjectID: "proj_1", EnvName: "dev"})["DB"] = "postgres://localhost"
+	e := &Envsec{Store: store, EnvID: EnvID{ProjectID: "proj_1", EnvName: "dev"}}
+	ctx := context.Background()
+
+	result, err := e.InjectReferences(ctx,
+		"prod=envsec:///prod/DB dev=envsec:///dev/DB again=envsec:///prod/DB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "prod=postgres://db dev=postgres://localhost again=postgres://db"
+	if result != expected {
+		t.Errorf("Expected %s, but got %s", expected, result)
+	}
+
+	if _, err := e.InjectReferences(ctx, "x=envsec:///prod/MISSING"); err == nil {
+		t.Error("Expected an error for a missing reference")
+	}
+
+	environ, err := e.ResolveEnviron(ctx, []string{"A=1", "DB=envsec:///prod/DB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(environ, []string{"A=1", "DB=postgres://db"}) {
+		t.Errorf("Unexpected environment %v", environ)
+	}
+}
@@ -0,0 +1,168 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+type RenderOptions struct {
+	// Output is the path of the rendered file. If empty or "-", the result is
+	// written to stdout.
+	Output string
+	// FileMode is the permission of the rendered file. Defaults to 0600.
+	FileMode os.FileMode
+}
+
+// Render evaluates the Go text/template at templatePath with the environment
+// variables of the environment. Variables are available as fields of the
+// template data, e.g. {{ .DATABASE_URL }}, and through the helper functions
+// in templateFuncs. Referencing a variable that doesn't exist is an error.
+func (e *Envsec) Render(ctx context.Context, templatePath string, opts RenderOptions) error {
+	_, err := e.render(ctx, templatePath, opts, nil)
+	return err
+}
+
+// WatchRender renders the template and then renders it again every interval,
+// writing the output only when it changed. It picks up changes to both the
+// template and the stored variables, and runs until ctx is done. Errors after
+// the first render are reported without stopping the watch.
+func (e *Envsec) WatchRender(
+	ctx context.Context,
+	templatePath string,
+	opts RenderOptions,
+	interval time.Duration,
+) error {
+	last, err := e.render(ctx, templatePath, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			output, err := e.render(ctx, templatePath, opts, last)
+			if err != nil {
+				_ = tux.WriteHeader(e.Stderr, "[ERROR] %v\n", err)
+				continue
+			}
+			last = output
+		}
+	}
+}
+
+// render renders the template and writes it, unless the output is equal to
+// previous. It returns the rendered output.
+func (e *Envsec) render(
+	ctx context.Context,
+	templatePath string,
+	opts RenderOptions,
+	previous []byte,
+) ([]byte, error) {
+	if !filepath.IsAbs(templatePath) {
+		templatePath = filepath.Join(e.WorkingDir, templatePath)
+	}
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	envVars, err := e.List(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	output, err := renderTemplate(filepath.Base(templatePath), string(text), envVarsToMap(envVars))
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && bytes.Equal(output, previous) {
+		return output, nil
+	}
+
+	if opts.Output == "" || opts.Output == "-" {
+		_, err = e.stdout().Write(output)
+		return output, errors.WithStack(err)
+	}
+	path := opts.Output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.WorkingDir, path)
+	}
+	if err := writeFileAtomic(path, output, opts.FileMode); err != nil {
+		return nil, err
+	}
+	return output, tux.WriteHeader(e.Stderr,
+		"[DONE] Rendered %q to %q for environment: %s\n",
+		templatePath,
+		path,
+		strings.ToLower(e.EnvID.EnvName),
+	)
+}
+
+func renderTemplate(name, text string, vars map[string]string) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(templateFuncs(vars)).
+		Parse(text)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b := bytes.Buffer{}
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return b.Bytes(), nil
+}
+
+func templateFuncs(vars map[string]string) template.FuncMap {
+	return template.FuncMap{
+		// env returns the value of a variable, or an empty string if it is not
+		// set. Unlike {{ .NAME }}, it can be combined with default.
+		"env": func(name string) string {
+			return vars[name]
+		},
+		// default returns def if value is empty: {{ env "PORT" | default "8080" }}
+		"default": func(def string, value ...string) string {
+			if len(value) == 0 || value[0] == "" {
+				return def
+			}
+			return value[0]
+		},
+		// required fails rendering with message if value is empty:
+		// {{ env "DATABASE_URL" | required "DATABASE_URL must be set" }}
+		"required": func(message, value string) (string, error) {
+			if value == "" {
+				return "", errors.New(message)
+			}
+			return value, nil
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"json": func(value any) (string, error) {
+			b := new(bytes.Buffer)
+			encoder := json.NewEncoder(b)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(value); err != nil {
+				return "", errors.WithStack(err)
+			}
+			return strings.TrimSuffix(b.String(), "\n"), nil
+		},
+		"quote": func(value string) string {
+			return fmt.Sprintf("%q", value)
+		},
+	}
+}
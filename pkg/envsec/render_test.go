@@ -0,0 +1,42 @@
+package envsec
+
+import (
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{"HOST": "db", "TOKEN": "a\"b"}
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{`host={{ .HOST }}`, `host=db`},
+		{`port={{ env "PORT" | default "5432" }}`, `port=5432`},
+		{`token={{ .TOKEN | quote }}`, `token="a\"b"`},
+		{`{"token": {{ .TOKEN | json }}}`, `{"token": "a\"b"}`},
+		{`{{ b64enc .HOST }}`, `ZGI=`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			result, err := renderTemplate("test", test.text, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != test.expected {
+				t.Errorf("Expected %s, but got %s", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateMissing(t *testing.T) {
+	for _, text := range []string{
+		`{{ .MISSING }}`,
+		`{{ env "MISSING" | required "MISSING is required" }}`,
+	} {
+		if _, err := renderTemplate("test", text, map[string]string{}); err == nil {
+			t.Errorf("Expected an error rendering %s", text)
+		}
+	}
+}
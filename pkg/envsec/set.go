@@ -4,8 +4,10 @@ import (
 	"context"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"go.jetify.com/envsec/internal/tux"
@@ -16,12 +18,31 @@ func (e *Envsec) Set(ctx context.Context, name, value string) error {
 }
 
 func (e *Envsec) SetMap(ctx context.Context, envMap map[string]string) error {
+	return e.SetMapIf(ctx, envMap, WriteConditions{})
+}
+
+// SetMapIf sets the variables in envMap if they meet cond, and otherwise fails
+// with ErrConflict without writing anything.
+func (e *Envsec) SetMapIf(
+	ctx context.Context,
+	envMap map[string]string,
+	cond WriteConditions,
+) error {
 	err := ensureValidNames(lo.Keys(envMap))
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	names := lo.Keys(envMap)
+	sort.Strings(names)
+	err = e.ensureWritable(e.EnvID.EnvName, "set "+strings.Join(names, ", "))
+	if err != nil {
+		return err
+	}
+	if err := e.checkConditions(ctx, names, cond); err != nil {
+		return err
+	}
 
-	err = e.Store.SetAll(ctx, e.EnvID, envMap)
+	err = e.setAll(ctx, envMap)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -35,11 +56,16 @@ func (e *Envsec) SetMap(ctx context.Context, envMap map[string]string) error {
 }
 
 func (e *Envsec) SetFromArgs(ctx context.Context, args []string) error {
-	envMap, err := parseSetArgs(args)
+	return e.SetFromArgsIf(ctx, args, WriteConditions{})
+}
+
+// SetFromArgsIf sets the variables in NAME=VALUE args if they meet cond.
+func (e *Envsec) SetFromArgsIf(ctx context.Context, args []string, cond WriteConditions) error {
+	envMap, err := ParseSetArgs(args)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	return e.SetMap(ctx, envMap)
+	return e.SetMapIf(ctx, envMap, cond)
 }
 
 func ValidateSetArgs(args []string) error {
@@ -56,7 +82,9 @@ func ValidateSetArgs(args []string) error {
 	return nil
 }
 
-func parseSetArgs(args []string) (map[string]string, error) {
+// ParseSetArgs parses NAME=VALUE args. Values of the form @file are read
+// from the file, and a leading \@ escapes a value that starts with @.
+func ParseSetArgs(args []string) (map[string]string, error) {
 	envMap := map[string]string{}
 	for _, arg := range args {
 		key, val, _ := strings.Cut(arg, "=")
@@ -85,29 +113,64 @@ func parseSetArgs(args []string) (map[string]string, error) {
 	return envMap, nil
 }
 
-const nameRegexStr = "^[a-zA-Z_][a-zA-Z0-9_]*"
+// reservedPrefix starts the names that envsec keeps for itself, such as
+// snapshots stored alongside the variables, in any case.
+const reservedPrefix = "JETPACK_"
+
+func isReservedName(name string) bool {
+	return strings.HasPrefix(strings.ToUpper(name), reservedPrefix)
+}
+
+// nameRegexStr matches whole names. It used to be unanchored, so names such
+// as API-KEY that merely started with a valid name could be set, but they
+// can't be exported to a shell.
+const nameRegexStr = "^[a-zA-Z_][a-zA-Z0-9_]*$"
 
 var nameRegex = regexp.MustCompile(nameRegexStr)
 
+var nonNameCharsRegex = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// normalizeName turns a key from a file into a valid name by replacing the
+// characters that names can't contain with underscores, e.g. API-KEY becomes
+// API_KEY, and by prefixing an underscore to keys that start with a digit.
+func normalizeName(key string) string {
+	name := nonNameCharsRegex.ReplaceAllString(key, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// ValidateNames checks that variables can be set with names, reporting every
+// invalid name at once.
+func ValidateNames(names ...string) error {
+	return errors.WithStack(ensureValidNames(names))
+}
+
+// ensureValidNames validates all names and reports every invalid one at once.
 func ensureValidNames(names []string) error {
+	// Report errors in a stable order without reordering the caller's slice.
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	var multiErr error
 	for _, name := range names {
 
 		// Any variation of jetpack_ or JETPACK_ prefix is not allowed
-		lowerName := strings.ToLower(name)
-		if strings.HasPrefix(lowerName, "jetpack_") {
-			return errors.Errorf(
+		if isReservedName(name) {
+			multiErr = multierror.Append(multiErr, errors.Errorf(
 				"name %s cannot start with JETPACK_ (or lowercase)",
 				name,
-			)
+			))
+			continue
 		}
 
 		if !nameRegex.MatchString(name) {
-			return errors.Errorf(
+			multiErr = multierror.Append(multiErr, errors.Errorf(
 				"name %s must match the regular expression: %s ",
 				name,
 				nameRegexStr,
-			)
+			))
 		}
 	}
-	return nil
+	return multiErr
 }
@@ -0,0 +1,436 @@
+package envsec
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetify.com/envsec/internal/seal"
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// SnapshotStorage is where a snapshot is kept.
+type SnapshotStorage string
+
+const (
+	// SnapshotStorageLocal keeps snapshots in the .jetify/snapshots directory
+	// of the project.
+	SnapshotStorageLocal SnapshotStorage = "local"
+	// SnapshotStorageRemote keeps snapshots in the store, next to the
+	// variables of the environment, under names reserved for envsec. Each
+	// snapshot is a single value, so stores that limit the length of values
+	// only hold snapshots of small environments. See ValueLimitedStore.
+	SnapshotStorageRemote SnapshotStorage = "remote"
+)
+
+// snapshotPrefix starts the names of the snapshots kept in the store.
+const snapshotPrefix = reservedPrefix + "SNAPSHOT_"
+
+// ValueLimitedStore is implemented by stores that limit the length of values,
+// such as AWS Parameter Store, which holds at most 4 KB per parameter.
+type ValueLimitedStore interface {
+	// MaxValueLength returns the maximum length of a value in bytes.
+	MaxValueLength() int
+}
+
+// Snapshot describes a copy of all the variables of an environment at some
+// point in time. The variables themselves are encrypted and only read when
+// the snapshot is restored.
+type Snapshot struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name,omitempty"`
+	Environment string          `json:"environment"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Variables   int             `json:"variables"`
+	Storage     SnapshotStorage `json:"-"`
+}
+
+// snapshotFile is how a snapshot is serialized, locally and in the store.
+type snapshotFile struct {
+	Snapshot
+	// Data is the age encrypted, armored JSON object of the variables.
+	Data string `json:"data"`
+}
+
+// SnapshotFormatVersion is the version of the encrypted content of the
+// snapshots written by CreateSnapshot, a JSON object like:
+//
+//	{
+//	  "version": 1,
+//	  "variables": {"NAME": "value", "CERT": "MIIK..."},
+//	  "encodings": {"CERT": "base64"}
+//	}
+//
+// Binary values are base64 encoded, and listed in encodings. Readers reject
+// snapshots with a newer version than they know about, and snapshots without
+// a version, which predate this format.
+const SnapshotFormatVersion = 1
+
+// snapshotData is the encrypted content of a snapshot.
+type snapshotData struct {
+	Version   int               `json:"version"`
+	Variables map[string]string `json:"variables"`
+	// Encodings are the encodings of binary variables. See encodeValues.
+	Encodings map[string]Encoding `json:"encodings,omitempty"`
+}
+
+// DefaultIdentityFile returns the path of the age identity that encrypts
+// snapshots unless IdentityFile is set. It is created on first use.
+func DefaultIdentityFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "envsec", "identity.txt"), nil
+}
+
+func (e *Envsec) identityPath() (string, error) {
+	if e.IdentityFile != "" {
+		return e.IdentityFile, nil
+	}
+	return DefaultIdentityFile()
+}
+
+func (e *Envsec) identity() (*age.X25519Identity, error) {
+	path, err := e.identityPath()
+	if err != nil {
+		return nil, err
+	}
+	return seal.LoadOrCreateIdentity(path)
+}
+
+// CreateSnapshot encrypts all the variables of the environment into a new
+// snapshot kept in storage. Variables inherited from other layers and local
+// overrides are not included. The snapshot is encrypted to recipients, or to
+// the identity in IdentityFile if there are none, in which case only that
+// identity can restore it.
+func (e *Envsec) CreateSnapshot(
+	ctx context.Context,
+	name string,
+	storage SnapshotStorage,
+	recipients ...age.Recipient,
+) (*Snapshot, error) {
+	// Remote snapshots are saved as a variable of the environment.
+	if storage == SnapshotStorageRemote {
+		if err := e.ensureWritable(e.EnvID.EnvName, "save a remote snapshot"); err != nil {
+			return nil, err
+		}
+	}
+	envVars, err := e.Store.List(ctx, e.EnvID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := map[string]string{}
+	for _, envVar := range envVars {
+		if !isReservedName(envVar.Name) {
+			values[envVar.Name] = envVar.Value
+		}
+	}
+
+	if len(recipients) == 0 {
+		identity, err := e.identity()
+		if err != nil {
+			return nil, err
+		}
+		recipients = []age.Recipient{identity.Recipient()}
+		path, err := e.identityPath()
+		if err != nil {
+			return nil, err
+		}
+		err = tux.WriteHeader(e.Stderr,
+			"[WARNING] Only the identity in %s can restore this snapshot. "+
+				"Encrypt it with --recipient or --passphrase to share it, "+
+				"or to keep it if that file is lost\n",
+			path,
+		)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	data := snapshotData{Version: SnapshotFormatVersion}
+	data.Variables, data.Encodings = encodeValues(values)
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ciphertext, err := seal.Encrypt(plaintext, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newSnapshotID()
+	if err != nil {
+		return nil, err
+	}
+	file := &snapshotFile{
+		Snapshot: Snapshot{
+			ID:          id,
+			Name:        name,
+			Environment: e.EnvID.EnvName,
+			CreatedAt:   time.Now().UTC().Truncate(time.Second),
+			Variables:   len(values),
+			Storage:     storage,
+		},
+		Data: string(ciphertext),
+	}
+	// Remote snapshots are compact, since stores may limit the size of values.
+	var serialized []byte
+	if storage == SnapshotStorageRemote {
+		serialized, err = json.Marshal(file)
+	} else {
+		serialized, err = json.MarshalIndent(file, "", "  ")
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch storage {
+	case SnapshotStorageLocal:
+		dir := e.snapshotDir()
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = writeFileAtomic(filepath.Join(dir, id+".json"), serialized, defaultFileMode)
+	case SnapshotStorageRemote:
+		if limited, ok := e.Store.(ValueLimitedStore); ok && len(serialized) > limited.MaxValueLength() {
+			return nil, errors.Errorf(
+				"the snapshot is %d bytes, but the store holds at most %d bytes per value. "+
+					"Use --storage local instead",
+				len(serialized),
+				limited.MaxValueLength(),
+			)
+		}
+		err = e.Store.Set(ctx, e.EnvID, remoteSnapshotName(id), string(serialized))
+	default:
+		return nil, errors.Errorf("unknown snapshot storage %q", storage)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save snapshot")
+	}
+	err = tux.WriteHeader(e.Stderr,
+		"[DONE] Created %s snapshot %s of %d %s in environment: %s\n",
+		storage,
+		id,
+		len(values),
+		tux.Plural(lo.Keys(values), "variable", "variables"),
+		e.EnvID.EnvName,
+	)
+	return &file.Snapshot, err
+}
+
+// ListSnapshots lists the local and remote snapshots of the environment,
+// oldest first.
+func (e *Envsec) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	files, err := e.snapshotFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Snapshot, 0, len(files))
+	for _, file := range files {
+		result = append(result, file.Snapshot)
+	}
+	return result, nil
+}
+
+// RestoreSnapshot sets the variables of the environment to their values in
+// the snapshot with the given ID or name, decrypted with identities, or with
+// the identity in IdentityFile if there are none. It prints the changes and
+// asks for confirmation before making them. Variables created after the
+// snapshot are only deleted if prune is set.
+func (e *Envsec) RestoreSnapshot(
+	ctx context.Context,
+	idOrName string,
+	prune bool,
+	identities ...age.Identity,
+) error {
+	file, err := e.findSnapshot(ctx, idOrName)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		identity, err := e.identity()
+		if err != nil {
+			return err
+		}
+		identities = []age.Identity{identity}
+	}
+	plaintext, err := seal.Decrypt([]byte(file.Data), identities...)
+	if err != nil {
+		return errors.Wrapf(err,
+			"failed to decrypt snapshot %s. Pass the identity or passphrase it was encrypted to",
+			file.ID,
+		)
+	}
+	data := snapshotData{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return errors.Wrapf(err, "failed to parse snapshot %s", file.ID)
+	}
+	if data.Version > SnapshotFormatVersion {
+		return errors.Errorf(
+			"snapshot %s has format v%d, but this version of envsec only reads up to v%d. "+
+				"Upgrade envsec to restore it",
+			file.ID,
+			data.Version,
+			SnapshotFormatVersion,
+		)
+	}
+	// Restoring a snapshot without variables would delete every variable with
+	// --prune, so snapshots in an older or unknown format are rejected.
+	if data.Version == 0 || data.Variables == nil {
+		return errors.Errorf(
+			"snapshot %s was written in an older format that this version of envsec can't read",
+			file.ID,
+		)
+	}
+	target, err := decodeValues(data.Variables, data.Encodings)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse snapshot %s", file.ID)
+	}
+
+	envVars, err := e.Store.List(ctx, e.EnvID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	current := map[string]string{}
+	for _, envVar := range envVars {
+		if !isReservedName(envVar.Name) {
+			current[envVar.Name] = envVar.Value
+		}
+	}
+	changes := DiffEnvVars(current, target, prune)
+
+	err = tux.WriteHeader(e.Stderr,
+		"Restoring snapshot %s of environment %s, created at %s, to environment: %s\n",
+		file.ID,
+		file.Environment,
+		file.CreatedAt.Local().Format(time.DateTime),
+		e.EnvID.EnvName,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := PrintChanges(e.Stderr, changes); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := e.confirmChanges("restore snapshot " + file.ID); err != nil {
+		return err
+	}
+	if err := e.applyChanges(ctx, target, changes); err != nil {
+		return err
+	}
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Restored snapshot %s with %d %s in environment: %s\n",
+		file.ID,
+		len(changes),
+		tux.Plural(changes, "change", "changes"),
+		e.EnvID.EnvName,
+	)
+}
+
+func (e *Envsec) findSnapshot(ctx context.Context, idOrName string) (*snapshotFile, error) {
+	files, err := e.snapshotFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var byName []snapshotFile
+	for _, file := range files {
+		if file.ID == idOrName {
+			return &file, nil
+		}
+		if file.Name == idOrName {
+			byName = append(byName, file)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return nil, errors.Errorf(
+			"snapshot %q not found in environment %s. Run `envsec snapshot ls` to list snapshots",
+			idOrName,
+			e.EnvID.EnvName,
+		)
+	case 1:
+		return &byName[0], nil
+	default:
+		return nil, errors.Errorf(
+			"%d snapshots are named %q. Restore one of them by ID", len(byName), idOrName)
+	}
+}
+
+// snapshotFiles reads the local and remote snapshots of the environment,
+// sorted by creation time.
+func (e *Envsec) snapshotFiles(ctx context.Context) ([]snapshotFile, error) {
+	files := []snapshotFile{}
+
+	entries, err := os.ReadDir(e.snapshotDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(e.snapshotDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		file := snapshotFile{}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse snapshot %s", path)
+		}
+		file.Storage = SnapshotStorageLocal
+		files = append(files, file)
+	}
+
+	envVars, err := e.Store.List(ctx, e.EnvID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, envVar := range envVars {
+		if !strings.HasPrefix(envVar.Name, snapshotPrefix) {
+			continue
+		}
+		file := snapshotFile{}
+		if err := json.Unmarshal([]byte(envVar.Value), &file); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse snapshot %s", envVar.Name)
+		}
+		file.Storage = SnapshotStorageRemote
+		files = append(files, file)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].CreatedAt.Before(files[j].CreatedAt)
+	})
+	return files, nil
+}
+
+func (e *Envsec) snapshotDir() string {
+	return filepath.Join(e.WorkingDir, dirName, "snapshots", e.EnvID.EnvName)
+}
+
+// newSnapshotID returns an ID that sorts by creation time, e.g.
+// 20241018161600-3fa2.
+func newSnapshotID() (string, error) {
+	suffix := make([]byte, 2)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return time.Now().UTC().Format("20060102150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// remoteSnapshotName is the name of the store variable that holds a
+// snapshot. It is a valid variable name, but reserved for envsec.
+func remoteSnapshotName(id string) string {
+	return snapshotPrefix + strings.ToUpper(strings.ReplaceAll(id, "-", "_"))
+}
@@ -0,0 +1,208 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.jetify.com/envsec/internal/seal"
+)
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:        store,
+		EnvID:        EnvID{ProjectID: "proj", EnvName: "dev"},
+		IdentityFile: filepath.Join(dir, "identity.txt"),
+		Stderr:       io.Discard,
+		WorkingDir:   dir,
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "1", "B": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := e.CreateSnapshot(ctx, "before", SnapshotStorageLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := e.CreateSnapshot(ctx, "", SnapshotStorageRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := e.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, but got %v", snapshots)
+	}
+
+	// Remote snapshots are hidden from the variables of the environment.
+	envVars, err := e.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envVars) != 2 {
+		t.Errorf("Expected only A and B to be listed, but got %v", envVars)
+	}
+
+	if err := e.SetMap(ctx, map[string]string{"A": "changed", "C": "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Stdin = strings.NewReader("n\n")
+	if err := e.RestoreSnapshot(ctx, "before", true); err == nil {
+		t.Error("Expected a declined restore to fail")
+	}
+	if store.env(e.EnvID)["A"] != "changed" {
+		t.Error("Expected a declined restore to change nothing")
+	}
+
+	e.Stdin = strings.NewReader("y\n")
+	if err := e.RestoreSnapshot(ctx, local.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": "2", "C": "3"}
+	if got := visibleVars(store.env(e.EnvID)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after restoring, but got %v", want, got)
+	}
+
+	e.AssumeYes = true
+	if err := e.RestoreSnapshot(ctx, remote.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{"A": "1", "B": "2"}
+	if got := visibleVars(store.env(e.EnvID)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after pruning, but got %v", want, got)
+	}
+	if snapshots, err := e.ListSnapshots(ctx); err != nil || len(snapshots) != 2 {
+		t.Errorf("Expected pruning to keep both snapshots, but got %v, %v", snapshots, err)
+	}
+}
+
+func visibleVars(values map[string]string) map[string]string {
+	result := map[string]string{}
+	for name, value := range values {
+		if !isReservedName(name) {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// smallValueStore is a memStore that limits the length of values.
+type smallValueStore struct {
+	*memStore
+}
+
+func (smallValueStore) MaxValueLength() int {
+	return 64
+}
+
+func TestRemoteSnapshotTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:        smallValueStore{newMemStore()},
+		EnvID:        EnvID{ProjectID: "proj", EnvName: "dev"},
+		IdentityFile: filepath.Join(dir, "identity.txt"),
+		Stderr:       io.Discard,
+		WorkingDir:   dir,
+	}
+	_, err := e.CreateSnapshot(context.Background(), "", SnapshotStorageRemote)
+	if err == nil || !strings.Contains(err.Error(), "--storage local") {
+		t.Errorf("Expected a snapshot larger than the store's limit to be rejected, but got %v", err)
+	}
+}
+
+func TestSnapshotPassphrase(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:        store,
+		EnvID:        EnvID{ProjectID: "proj", EnvName: "dev"},
+		IdentityFile: filepath.Join(dir, "identity.txt"),
+		Stderr:       io.Discard,
+		WorkingDir:   dir,
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	recipient, identity, err := seal.Passphrase("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := e.CreateSnapshot(ctx, "", SnapshotStorageRemote, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The local identity can't restore a snapshot encrypted to a passphrase.
+	if err := e.RestoreSnapshot(ctx, snapshot.ID, false); err == nil {
+		t.Fatal("Expected restoring with the local identity to fail")
+	}
+	e.Stdin = strings.NewReader("y\n")
+	if err := e.RestoreSnapshot(ctx, snapshot.ID, false, identity); err != nil {
+		t.Fatal(err)
+	}
+	if value := store.env(e.EnvID)["A"]; value != "1" {
+		t.Errorf("Expected A to be restored to 1, but got %q", value)
+	}
+}
+
+func TestRestoreSnapshotWithoutVersion(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	dir := t.TempDir()
+	e := &Envsec{
+		Store:        store,
+		EnvID:        EnvID{ProjectID: "proj", EnvName: "dev"},
+		IdentityFile: filepath.Join(dir, "identity.txt"),
+		Stderr:       io.Discard,
+		Stdin:        strings.NewReader("y\n"),
+		WorkingDir:   dir,
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := e.CreateSnapshot(ctx, "", SnapshotStorageLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots used to hold a flat map of the variables.
+	identity, err := e.identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := seal.Encrypt([]byte(`{"A": "1"}`), identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(e.snapshotDir(), snapshot.ID+".json")
+	file := snapshotFile{Snapshot: *snapshot, Data: string(ciphertext)}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.RestoreSnapshot(ctx, snapshot.ID, true); err == nil {
+		t.Error("Expected a snapshot without a format version to be rejected")
+	}
+	if store.env(e.EnvID)["A"] != "1" {
+		t.Error("Expected a rejected snapshot to change nothing")
+	}
+}
@@ -0,0 +1,93 @@
+package envsec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.jetify.com/pkg/auth/session"
+)
+
+// memStore is an in-memory Store used by tests.
+type memStore struct {
+	envs map[EnvID]map[string]string
+}
+
+var _ Store = (*memStore)(nil)
+
+func newMemStore() *memStore {
+	return &memStore{envs: map[EnvID]map[string]string{}}
+}
+
+func (m *memStore) env(envID EnvID) map[string]string {
+	if m.envs[envID] == nil {
+		m.envs[envID] = map[string]string{}
+	}
+	return m.envs[envID]
+}
+
+func (m *memStore) List(ctx context.Context, envID EnvID) ([]EnvVar, error) {
+	result := []EnvVar{}
+	for name, value := range m.env(envID) {
+		result = append(result, EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *memStore) Set(ctx context.Context, envID EnvID, name, value string) error {
+	m.env(envID)[name] = value
+	return nil
+}
+
+func (m *memStore) SetAll(ctx context.Context, envID EnvID, values map[string]string) error {
+	for name, value := range values {
+		m.env(envID)[name] = value
+	}
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, envID EnvID, name string) (string, error) {
+	return m.env(envID)[name], nil
+}
+
+func (m *memStore) GetAll(ctx context.Context, envID EnvID, names []string) ([]EnvVar, error) {
+	result := []EnvVar{}
+	for _, name := range names {
+		if value, ok := m.env(envID)[name]; ok {
+			result = append(result, EnvVar{Name: name, Value: value})
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) Delete(ctx context.Context, envID EnvID, name string) error {
+	delete(m.env(envID), name)
+	return nil
+}
+
+func (m *memStore) DeleteAll(ctx context.Context, envID EnvID, names []string) error {
+	for _, name := range names {
+		delete(m.env(envID), name)
+	}
+	return nil
+}
+
+func (m *memStore) InitForUser(ctx context.Context, e *Envsec) (*session.Token, error) {
+	return nil, nil
+}
+
+// writeConfigForTest writes a project config with the given environments.
+func (e *Envsec) writeConfigForTest(environments map[string]EnvironmentConfig) error {
+	if err := os.MkdirAll(filepath.Join(e.WorkingDir, dirName), 0o700); err != nil {
+		return err
+	}
+	if err := e.writeConfig(&projectConfig{}); err != nil {
+		return err
+	}
+	if environments == nil {
+		return nil
+	}
+	return e.writeEnvironments(environments)
+}
@@ -0,0 +1,140 @@
+package envsec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AtomicStore is implemented by stores whose SetAll and DeleteAll either apply
+// all changes or none of them. Transactional writes to these stores don't
+// need to be rolled back.
+type AtomicStore interface {
+	SupportsAtomicWrites() bool
+}
+
+// RollbackError is returned by transactional writes that failed. It reports
+// which of the variables that were changed before the failure could be
+// restored to their previous values.
+type RollbackError struct {
+	// Err is the error that caused the rollback.
+	Err error
+	// RolledBack are the names of the variables that were restored.
+	RolledBack []string
+	// Failed maps the names of the variables that could not be restored to the
+	// reason why.
+	Failed map[string]error
+}
+
+func (e *RollbackError) Error() string {
+	msg := strings.Builder{}
+	msg.WriteString(e.Err.Error())
+	if len(e.RolledBack) > 0 {
+		fmt.Fprintf(&msg, ". Rolled back: %s", strings.Join(e.RolledBack, ", "))
+	}
+	if len(e.Failed) > 0 {
+		names := make([]string, 0, len(e.Failed))
+		for name := range e.Failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		msg.WriteString(". Failed to roll back: ")
+		for i, name := range names {
+			if i > 0 {
+				msg.WriteString("; ")
+			}
+			fmt.Fprintf(&msg, "%s (%v)", name, e.Failed[name])
+		}
+	}
+	if len(e.RolledBack) == 0 && len(e.Failed) == 0 {
+		msg.WriteString(". No variables were changed")
+	}
+	return msg.String()
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// setAll writes envMap to the store, in a transaction if Transactional is set.
+func (e *Envsec) setAll(ctx context.Context, envMap map[string]string) error {
+	names := make([]string, 0, len(envMap))
+	for name := range envMap {
+		names = append(names, name)
+	}
+	return e.transaction(ctx, names, func() error {
+		return e.Store.SetAll(ctx, e.EnvID, envMap)
+	})
+}
+
+// deleteAll deletes names from the store, in a transaction if Transactional
+// is set.
+func (e *Envsec) deleteAll(ctx context.Context, names []string) error {
+	return e.transaction(ctx, names, func() error {
+		return e.Store.DeleteAll(ctx, e.EnvID, names)
+	})
+}
+
+// transaction runs write, which changes the variables in names. If
+// Transactional is set and the store isn't atomic, the variables are read
+// first so that any changes can be undone if write fails.
+func (e *Envsec) transaction(ctx context.Context, names []string, write func() error) error {
+	if atomic, ok := e.Store.(AtomicStore); !e.Transactional || (ok && atomic.SupportsAtomicWrites()) {
+		return write()
+	}
+
+	before, err := e.Store.GetAll(ctx, e.EnvID, names)
+	if err != nil {
+		return errors.Wrap(err, "failed to read variables before writing them")
+	}
+	err = write()
+	if err == nil {
+		return nil
+	}
+	return e.rollback(ctx, names, envVarsToMap(before), err)
+}
+
+// rollback restores the variables in names to their values in before, which
+// omits the variables that didn't exist.
+func (e *Envsec) rollback(
+	ctx context.Context,
+	names []string,
+	before map[string]string,
+	cause error,
+) error {
+	result := &RollbackError{Err: cause, Failed: map[string]error{}}
+
+	// Only restore the variables that actually changed. If they can't be read,
+	// restore all of them.
+	changed := names
+	if after, err := e.Store.GetAll(ctx, e.EnvID, names); err == nil {
+		afterMap := envVarsToMap(after)
+		changed = []string{}
+		for _, name := range names {
+			oldValue, existed := before[name]
+			newValue, exists := afterMap[name]
+			if existed != exists || oldValue != newValue {
+				changed = append(changed, name)
+			}
+		}
+	}
+	sort.Strings(changed)
+
+	for _, name := range changed {
+		var err error
+		if value, ok := before[name]; ok {
+			err = e.Store.Set(ctx, e.EnvID, name, value)
+		} else {
+			err = e.Store.Delete(ctx, e.EnvID, name)
+		}
+		if err != nil {
+			result.Failed[name] = err
+		} else {
+			result.RolledBack = append(result.RolledBack, name)
+		}
+	}
+	return result
+}
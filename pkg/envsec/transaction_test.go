@@ -0,0 +1,77 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// failingStore is a memStore whose writes fail for the names in fail, after
+// applying the writes for all other names.
+type failingStore struct {
+	*memStore
+	fail map[string]bool
+}
+
+func (f *failingStore) SetAll(ctx context.Context, envID EnvID, values map[string]string) error {
+	for name, value := range values {
+		if !f.fail[name] {
+			f.env(envID)[name] = value
+		}
+	}
+	return errors.New("set failed")
+}
+
+func (f *failingStore) DeleteAll(ctx context.Context, envID EnvID, names []string) error {
+	for _, name := range names {
+		if !f.fail[name] {
+			delete(f.env(envID), name)
+		}
+	}
+	return errors.New("delete failed")
+}
+
+func (f *failingStore) Delete(ctx context.Context, envID EnvID, name string) error {
+	if name == "NEW" {
+		return errors.New("permission denied")
+	}
+	return f.memStore.Delete(ctx, envID, name)
+}
+
+func TestTransactionalWrites(t *testing.T) {
+	ctx := context.Background()
+	envID := EnvID{ProjectID: "proj", EnvName: "dev"}
+	store := &failingStore{memStore: newMemStore(), fail: map[string]bool{"C": true}}
+	store.env(envID)["A"] = "a"
+	store.env(envID)["C"] = "c"
+	e := &Envsec{Store: store, EnvID: envID, Stderr: io.Discard, Transactional: true}
+
+	err := e.SetMap(ctx, map[string]string{"A": "changed", "B": "new", "C": "changed"})
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("Expected a RollbackError, but got %v", err)
+	}
+	if !reflect.DeepEqual(rollbackErr.RolledBack, []string{"A", "B"}) {
+		t.Errorf("Expected A and B to be rolled back, but got %v", rollbackErr.RolledBack)
+	}
+	expected := map[string]string{"A": "a", "C": "c"}
+	if !reflect.DeepEqual(store.env(envID), expected) {
+		t.Errorf("Expected %v after rollback, but got %v", expected, store.env(envID))
+	}
+
+	err = e.DeleteAll(ctx, "A", "C")
+	if !errors.As(err, &rollbackErr) || !reflect.DeepEqual(rollbackErr.RolledBack, []string{"A"}) {
+		t.Errorf("Expected A to be rolled back, but got %v", err)
+	}
+	if !reflect.DeepEqual(store.env(envID), expected) {
+		t.Errorf("Expected %v after rollback, but got %v", expected, store.env(envID))
+	}
+
+	err = e.SetMap(ctx, map[string]string{"NEW": "x", "C": "changed"})
+	if !errors.As(err, &rollbackErr) || rollbackErr.Failed["NEW"] == nil {
+		t.Errorf("Expected rolling back NEW to fail, but got %v", err)
+	}
+}
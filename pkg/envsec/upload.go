@@ -2,20 +2,37 @@ package envsec
 
 import (
 	"context"
-	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.jetify.com/pkg/fileutil"
 )
 
+type UploadOptions struct {
+	// Format of the files, one of the formats that has a decoder. If empty, the
+	// format of each file is detected from its extension and content.
+	Format string
+	// Separator joins the keys of nested values, so that database.host becomes
+	// DATABASE_HOST. Defaults to "_".
+	Separator string
+	// IfUnchangedSince makes the upload fail with ErrConflict, without writing
+	// anything, if any of the uploaded variables was modified remotely after
+	// this time.
+	IfUnchangedSince time.Time
+	// NormalizeNames replaces the characters that variable names can't
+	// contain, such as dashes, with underscores. Otherwise files with such
+	// names are rejected, listing all of them.
+	NormalizeNames bool
+}
+
 // Upload uploads the environment variables for the environment specified from
 // the given paths.
-// If format is empty, we default to dotenv format unless path ends in .json
-func (e *Envsec) Upload(ctx context.Context, paths []string, format string) error {
-	if err := ValidateFormat(format); err != nil {
+func (e *Envsec) Upload(ctx context.Context, paths []string, opts UploadOptions) error {
+	if err := ValidateUploadFormat(opts.Format); err != nil {
 		return err
 	}
 
@@ -32,52 +49,68 @@ func (e *Envsec) Upload(ctx context.Context, paths []string, format string) erro
 	}
 
 	envMap := map[string]string{}
-	var err error
 	for _, path := range filePaths {
-		var newVars map[string]string
-		if format == "json" || (format == "" && filepath.Ext(path) == ".json") {
-			newVars, err = loadFromJSON([]string{path})
-			if err != nil {
-				return errors.Wrap(
-					err,
-					"failed to load from JSON. Ensure the file is a flat key-value "+
-						"JSON formatted file",
-				)
-			}
-		} else {
-			newVars, err = godotenv.Read(path)
-			if err != nil {
-				return errors.WithStack(err)
-			}
+		newVars, err := loadFile(path, opts)
+		if err != nil {
+			return err
 		}
 		for k, v := range newVars {
 			envMap[k] = v
 		}
 	}
+	if err := ensureValidNames(lo.Keys(envMap)); err != nil {
+		return errors.Wrap(err,
+			"invalid variable names. Pass --normalize-names to replace the characters "+
+				"names can't contain with underscores")
+	}
 
-	return e.SetMap(ctx, envMap)
+	return e.SetMapIf(ctx, envMap, WriteConditions{IfUnchangedSince: opts.IfUnchangedSince})
 }
 
-func loadFromJSON(filePaths []string) (map[string]string, error) {
-	envMap := map[string]string{}
-	for _, filePath := range filePaths {
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		if err = json.Unmarshal(content, &envMap); err != nil {
-			return nil, errors.WithStack(err)
+func loadFile(path string, opts UploadOptions) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	format, err := detectFormat(opts.Format, path, data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := format.Decode(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s as %s", path, format.Name)
+	}
+	vars := map[string]string{}
+	if format.Name == "dotenv" {
+		// dotenv files are already flat and their names are used as written.
+		for k, v := range doc {
+			vars[k] = v.(string)
 		}
-		for k, v := range envMap {
-			envMap[k] = v
+	} else if vars, err = flatten(doc, opts.Separator); err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", path)
+	}
+	if opts.NormalizeNames {
+		if vars, err = renameKeys(vars, normalizeName); err != nil {
+			return nil, errors.Wrapf(err, "failed to load %s", path)
 		}
 	}
-	return envMap, nil
+	return vars, nil
 }
 
-func ValidateFormat(format string) error {
-	if format != "" && format != "json" && format != "dotenv" {
-		return errors.Errorf("incorrect format. Must be one of json|dotenv")
+// ValidateUploadFormat validates the format of files that can be uploaded,
+// which is a subset of the formats that can be downloaded.
+func ValidateUploadFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	if f, ok := LookupFormat(format); ok && f.Decode != nil {
+		return nil
+	}
+	names := []string{}
+	for _, f := range formats {
+		if f.Decode != nil {
+			names = append(names, f.Name)
+		}
 	}
-	return nil
+	return errors.Errorf("incorrect format. Must be one of %s", strings.Join(names, "|"))
 }
@@ -0,0 +1,105 @@
+package envsec
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrConflict is returned by conditional writes when a variable changed
+	// since the version the write was based on.
+	ErrConflict = errors.New("conflict")
+	// ErrVersionsNotSupported is returned when a conditional write or a
+	// version is requested from a store that doesn't implement VersionedStore.
+	ErrVersionsNotSupported = errors.New("store does not track versions of variables")
+)
+
+// NoVersion is the version of a variable that doesn't exist. Writes
+// conditioned on it only succeed if the variable hasn't been created yet.
+const NoVersion = "0"
+
+// Version identifies a revision of a stored variable.
+type Version struct {
+	// ID changes every time the variable is written.
+	ID           string
+	LastModified time.Time
+}
+
+// VersionedStore is implemented by stores that track a version per variable,
+// which makes conditional writes possible.
+type VersionedStore interface {
+	// ListVersions returns the current version of each variable of envID.
+	ListVersions(ctx context.Context, envID EnvID) (map[string]Version, error)
+}
+
+// WriteConditions make a write fail with ErrConflict if the variables changed
+// since they were last read. The zero value writes unconditionally.
+type WriteConditions struct {
+	// IfVersion maps names to the version ID each variable must still have.
+	// Use NoVersion for variables that must not exist yet.
+	IfVersion map[string]string
+	// IfUnchangedSince requires that none of the written variables were
+	// modified after this time.
+	IfUnchangedSince time.Time
+}
+
+func (c WriteConditions) isZero() bool {
+	return len(c.IfVersion) == 0 && c.IfUnchangedSince.IsZero()
+}
+
+// ListVersions returns the current version of each variable of the
+// environment.
+func (e *Envsec) ListVersions(ctx context.Context) (map[string]Version, error) {
+	versioned, ok := e.Store.(VersionedStore)
+	if !ok {
+		return nil, errors.WithStack(ErrVersionsNotSupported)
+	}
+	versions, err := versioned.ListVersions(ctx, e.EnvID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return versions, nil
+}
+
+// checkConditions fails with ErrConflict if the variables in names don't
+// meet cond. Stores don't offer atomic conditional writes, so a change that
+// happens between the check and the write can still be overwritten, but the
+// window is small compared to a read-modify-write done by hand.
+func (e *Envsec) checkConditions(ctx context.Context, names []string, cond WriteConditions) error {
+	if cond.isZero() {
+		return nil
+	}
+	versions, err := e.ListVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	conflicts := []string{}
+	for name, expected := range cond.IfVersion {
+		current := NoVersion
+		if version, ok := versions[name]; ok {
+			current = version.ID
+		}
+		if current != expected {
+			conflicts = append(conflicts, name+" is at version "+current+", expected "+expected)
+		}
+	}
+	if !cond.IfUnchangedSince.IsZero() {
+		for _, name := range names {
+			if version, ok := versions[name]; ok && version.LastModified.After(cond.IfUnchangedSince) {
+				conflicts = append(conflicts, name+" was modified at "+
+					version.LastModified.Format(time.RFC3339))
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return errors.Wrapf(ErrConflict,
+			"variables changed remotely: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
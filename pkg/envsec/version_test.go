@@ -0,0 +1,73 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// versionedMemStore is a memStore that tracks a version per variable.
+type versionedMemStore struct {
+	*memStore
+	versions map[string]Version
+	now      time.Time
+}
+
+func (m *versionedMemStore) SetAll(ctx context.Context, envID EnvID, values map[string]string) error {
+	for name := range values {
+		id, _ := strconv.Atoi(m.versions[name].ID)
+		m.versions[name] = Version{ID: strconv.Itoa(id + 1), LastModified: m.now}
+	}
+	return m.memStore.SetAll(ctx, envID, values)
+}
+
+func (m *versionedMemStore) ListVersions(ctx context.Context, envID EnvID) (map[string]Version, error) {
+	return m.versions, nil
+}
+
+func TestConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &versionedMemStore{memStore: newMemStore(), versions: map[string]Version{}, now: start}
+	e := &Envsec{Store: store, EnvID: EnvID{ProjectID: "proj", EnvName: "dev"}, Stderr: io.Discard}
+
+	ifVersion := func(name, version string) WriteConditions {
+		return WriteConditions{IfVersion: map[string]string{name: version}}
+	}
+	if err := e.SetMapIf(ctx, map[string]string{"A": "1"}, ifVersion("A", NoVersion)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetMapIf(ctx, map[string]string{"A": "2"}, ifVersion("A", NoVersion)); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for an existing variable, but got %v", err)
+	}
+	if err := e.SetMapIf(ctx, map[string]string{"A": "2"}, ifVersion("A", "1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetMapIf(ctx, map[string]string{"A": "3"}, ifVersion("A", "1")); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for an outdated version, but got %v", err)
+	}
+
+	store.now = start.Add(time.Hour)
+	if err := e.Set(ctx, "B", "1"); err != nil {
+		t.Fatal(err)
+	}
+	since := WriteConditions{IfUnchangedSince: start.Add(time.Minute)}
+	if err := e.SetMapIf(ctx, map[string]string{"A": "3", "C": "1"}, since); err != nil {
+		t.Errorf("Expected write of unchanged variables to succeed, but got %v", err)
+	}
+	if err := e.SetMapIf(ctx, map[string]string{"B": "2"}, since); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a recently modified variable, but got %v", err)
+	}
+	if value := store.env(e.EnvID)["B"]; value != "1" {
+		t.Errorf("Expected a conflicting write not to change the value, but got %q", value)
+	}
+
+	e.Store = newMemStore()
+	if err := e.SetMapIf(ctx, map[string]string{"A": "1"}, since); !errors.Is(err, ErrVersionsNotSupported) {
+		t.Errorf("Expected ErrVersionsNotSupported, but got %v", err)
+	}
+}
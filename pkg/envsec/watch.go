@@ -0,0 +1,66 @@
+package envsec
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.jetify.com/envsec/internal/tux"
+)
+
+// Watch calls onChange with the new variables and the sorted names of the
+// variables that were added, removed or modified, whenever the variables of
+// the environment differ from current. It lists the variables every interval
+// until ctx is done. Failures to list them are reported on Stderr, and the
+// next interval tries again.
+func (e *Envsec) Watch(
+	ctx context.Context,
+	current []EnvVar,
+	interval time.Duration,
+	onChange func(envVars []EnvVar, changed []string),
+) {
+	check := func() {
+		envVars, err := e.List(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				_ = tux.WriteHeader(e.Stderr, "[ERROR] Failed to check for changes: %v\n", err)
+			}
+			return
+		}
+		if changed := changedNames(current, envVars); len(changed) > 0 {
+			current = envVars
+			onChange(envVars, changed)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// changedNames returns the sorted names of the variables that differ between
+// before and after.
+func changedNames(before, after []EnvVar) []string {
+	beforeMap := envVarsToMap(before)
+	afterMap := envVarsToMap(after)
+	changed := []string{}
+	for name, value := range afterMap {
+		if old, ok := beforeMap[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range beforeMap {
+		if _, ok := afterMap[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"connectrpc.com/connect"
+	"github.com/samber/lo"
 	"go.jetify.com/envsec/pkg/envsec"
 	"go.jetify.com/pkg/api"
 	secretsv1alpha1 "go.jetify.com/pkg/api/gen/priv/secrets/v1alpha1"
@@ -18,6 +19,15 @@ type JetpackAPIStore struct {
 // JetpackAPIStore implements interface Store (compile-time check)
 var _ envsec.Store = (*JetpackAPIStore)(nil)
 
+// JetpackAPIStore reports whether it writes atomically (compile-time check)
+var _ envsec.AtomicStore = (*JetpackAPIStore)(nil)
+
+// JetpackAPIStore only supports the default environments (compile-time check)
+var _ envsec.EnvironmentLimitedStore = (*JetpackAPIStore)(nil)
+
+// JetpackAPIStore lists the environments that have variables (compile-time check)
+var _ envsec.EnvironmentLister = (*JetpackAPIStore)(nil)
+
 func (j *JetpackAPIStore) InitForUser(
 	ctx context.Context,
 	envsec *envsec.Envsec,
@@ -105,6 +115,41 @@ func (j JetpackAPIStore) SetAll(ctx context.Context, envID envsec.EnvID, values
 	return err
 }
 
+// SupportsAtomicWrites reports that SetAll and DeleteAll aren't known to be
+// atomic. They send all changes in a single Batch request, but the API only
+// documents Batch as composing several requests, not as all-or-nothing, so
+// transactional writes still read the variables first to roll them back.
+func (j JetpackAPIStore) SupportsAtomicWrites() bool {
+	return false
+}
+
+// SupportedEnvironments returns the only environment names the Jetify API
+// accepts.
+func (j JetpackAPIStore) SupportedEnvironments() []string {
+	return []string{"dev", "preview", "prod"}
+}
+
+// ListEnvironments returns the environments of the project that have
+// variables.
+func (j JetpackAPIStore) ListEnvironments(ctx context.Context, envID envsec.EnvID) ([]string, error) {
+	resp, err := j.client.ListSecrets(
+		ctx,
+		connect.NewRequest(&secretsv1alpha1.ListSecretsRequest{ProjectId: envID.ProjectID}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	envNames := []string{}
+	for _, secret := range resp.Msg.Secrets {
+		for envName, v := range secret.EnvironmentValues {
+			if len(v) > 0 && !lo.Contains(envNames, envName) {
+				envNames = append(envNames, envName)
+			}
+		}
+	}
+	return envNames, nil
+}
+
 func (j JetpackAPIStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
 	vars, err := j.List(ctx, envID)
 	if err != nil {
@@ -5,6 +5,7 @@ package ssmstore
 
 import (
 	"context"
+	"encoding/base64"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -23,6 +24,14 @@ import (
 
 const emptyStringValuePlaceholder = "__###EMPTY_STRING###__"
 
+// Parameters only hold text, so binary values are stored base64 encoded. The
+// encoding is recorded in a tag rather than in the value, so that other
+// readers of the parameter get plain base64.
+const (
+	encodingTagKey    = "encoding"
+	encodingTagBase64 = "base64"
+)
+
 type parameter struct {
 	id          string
 	description string
@@ -69,16 +78,20 @@ func newParameterStore(ctx context.Context, config *SSMConfig) (*parameterStore,
 // Defines a new stored parameter.
 // parameter values are limited in size to 4 KB.
 func (s *parameterStore) newParameter(ctx context.Context, param *parameter, value string) error {
-	if parameterValueMaxLength < len(value) {
+	if parameterValueMaxLength < len(*awsSSMParamStoreValue(value)) {
 		return errors.New("parameter values are limited in size to 4KB")
 	}
 
+	tags := param.tags
+	if envsec.IsBinary(value) {
+		tags = append(tags, encodingTag())
+	}
 	input := &ssm.PutParameterInput{
 		Name:        aws.String(param.id),
 		Description: aws.String(param.description),
 		Type:        types.ParameterTypeSecureString,
 		Value:       awsSSMParamStoreValue(value),
-		Tags:        param.tags,
+		Tags:        tags,
 	}
 
 	// Set the KmsKeyId only when it is present. Otherwise, aws sdk uses the default KMS key
@@ -99,19 +112,90 @@ func (s *parameterStore) newParameter(ctx context.Context, param *parameter, val
 	return errors.WithStack(err)
 }
 
-// Updates a stored parameter.
+// Updates a stored parameter. Tags can't be passed when overwriting a
+// parameter, so the encoding tag is updated separately, and only when the
+// encoding changes. The two updates aren't atomic, so they are ordered to fail
+// safe: a binary value is only written once its parameter is tagged, and the
+// tag of a binary value overwritten by text is removed afterwards. A leftover
+// tag on text is harmless, since only values that are the base64 encoding of
+// binary data are decoded.
 func (s *parameterStore) overwriteParameterValue(ctx context.Context, v *parameter, value string) error {
+	encoded, err := s.isEncoded(ctx, v.id)
+	if err != nil {
+		return err
+	}
+	binary := envsec.IsBinary(value)
+	if binary && !encoded {
+		if err := s.setEncodingTag(ctx, v.id, true); err != nil {
+			return err
+		}
+	}
+
 	input := &ssm.PutParameterInput{
 		Name:        aws.String(v.id),
 		Description: aws.String(v.description),
 		Overwrite:   lo.ToPtr(true),
 		Value:       awsSSMParamStoreValue(value),
 	}
-	_, err := s.client.PutParameter(ctx, input)
+	if _, err := s.client.PutParameter(ctx, input); err != nil {
+		if binary && !encoded {
+			// Best effort, the previous value is still text.
+			_ = s.setEncodingTag(ctx, v.id, false)
+		}
+		return errors.WithStack(err)
+	}
+
+	if !binary && encoded {
+		return s.setEncodingTag(ctx, v.id, false)
+	}
+	return nil
+}
+
+// isEncoded reports whether the parameter at path is tagged as base64
+// encoded. It uses DescribeParameters, which listing variables already
+// requires, rather than reading the tags of the parameter.
+func (s *parameterStore) isEncoded(ctx context.Context, path string) (bool, error) {
+	paths, err := s.describePaths(ctx, []types.ParameterStringFilter{
+		{
+			Key:    lo.ToPtr("Name"),
+			Option: lo.ToPtr("Equals"),
+			Values: []string{path},
+		},
+		{
+			Key:    lo.ToPtr("tag:" + encodingTagKey),
+			Values: []string{encodingTagBase64},
+		},
+	})
+	return len(paths) > 0, err
+}
+
+// setEncodingTag adds or removes the encoding tag of the parameter at path.
+func (s *parameterStore) setEncodingTag(ctx context.Context, path string, encoded bool) error {
+	if encoded {
+		_, err := s.client.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceId:   aws.String(path),
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			Tags:         []types.Tag{encodingTag()},
+		})
+		return errors.WithStack(err)
+	}
+	_, err := s.client.RemoveTagsFromResource(ctx, &ssm.RemoveTagsFromResourceInput{
+		ResourceId:   aws.String(path),
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		TagKeys:      []string{encodingTagKey},
+	})
 	return errors.WithStack(err)
 }
 
 func (s *parameterStore) listByPath(ctx context.Context, id envsec.EnvID) ([]envsec.EnvVar, error) {
+	params, err := s.parametersByPath(ctx, id)
+	if err != nil {
+		return []envsec.EnvVar{}, err
+	}
+	return s.toEnvVars(ctx, id, params)
+}
+
+func (s *parameterStore) parametersByPath(ctx context.Context, id envsec.EnvID) ([]types.Parameter, error) {
 	// Create the request object:
 	req := &ssm.GetParametersByPathInput{
 		Path:           aws.String(s.config.varPath(id, "")),
@@ -120,7 +204,7 @@ func (s *parameterStore) listByPath(ctx context.Context, id envsec.EnvID) ([]env
 	}
 
 	// Start with empty results
-	results := []envsec.EnvVar{}
+	results := []types.Parameter{}
 
 	// Paginate through the results:
 	paginator := ssm.NewGetParametersByPathPaginator(s.client, req)
@@ -132,43 +216,94 @@ func (s *parameterStore) listByPath(ctx context.Context, id envsec.EnvID) ([]env
 		}
 
 		// Append results:
-		params := resp.Parameters
-		for _, p := range params {
-			results = append(results, envsec.EnvVar{
-				Name:  nameFromPath(aws.ToString(p.Name)),
-				Value: awsSSMParamStoreValueToString(p.Value),
-			})
-		}
+		results = append(results, resp.Parameters...)
 	}
-	sort(results)
-
 	return results, nil
 }
 
 func (s *parameterStore) listByTags(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	varNames, err := s.namesByTags(ctx, envID)
+	if err != nil {
+		return []envsec.EnvVar{}, err
+	}
+	return s.getAll(ctx, envID, varNames)
+}
+
+func (s *parameterStore) namesByTags(ctx context.Context, envID envsec.EnvID) ([]string, error) {
+	return s.describeNames(ctx, s.buildFilters(envID))
+}
+
+// encodedNames returns the names of the variables of envID whose values are
+// base64 encoded.
+func (s *parameterStore) encodedNames(ctx context.Context, envID envsec.EnvID) ([]string, error) {
+	filters := append(s.buildFilters(envID), types.ParameterStringFilter{
+		Key:    lo.ToPtr("tag:" + encodingTagKey),
+		Values: []string{encodingTagBase64},
+	})
+	return s.describeNames(ctx, filters)
+}
+
+func (s *parameterStore) describeNames(
+	ctx context.Context,
+	filters []types.ParameterStringFilter,
+) ([]string, error) {
+	paths, err := s.describePaths(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(paths, func(p string, _ int) string { return nameFromPath(p) }), nil
+}
+
+// describePaths returns the paths of the parameters that match filters.
+func (s *parameterStore) describePaths(
+	ctx context.Context,
+	filters []types.ParameterStringFilter,
+) ([]string, error) {
 	// Create the request object:
 	req := &ssm.DescribeParametersInput{
-		ParameterFilters: s.buildFilters(envID),
+		ParameterFilters: filters,
 	}
 
-	varNames := []string{}
+	paths := []string{}
 	// Paginate through the results:
 	paginator := ssm.NewDescribeParametersPaginator(s.client, req)
 	for paginator.HasMorePages() {
 		// Issue the request for the next page:
 		resp, err := paginator.NextPage(ctx)
 		if err != nil {
-			return []envsec.EnvVar{}, errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
 		// Append results:
 		for _, p := range resp.Parameters {
 			// AWS returns the parameter path as its "name":
-			varName := nameFromPath(aws.ToString(p.Name))
-			varNames = append(varNames, varName)
+			paths = append(paths, aws.ToString(p.Name))
 		}
 	}
+	return paths, nil
+}
 
-	return s.getAll(ctx, envID, varNames)
+// environmentNames returns the names of the environments of the project of
+// envID that have parameters. They can only be told from the parameter paths
+// when the paths are the default ones.
+func (s *parameterStore) environmentNames(ctx context.Context, envID envsec.EnvID) ([]string, error) {
+	if !s.config.hasDefaultPaths() {
+		return nil, nil
+	}
+	envID.EnvName = ""
+	projectPath := s.config.varPath(envID, "")
+	paths, err := s.describePaths(ctx, s.buildFilters(envID))
+	if err != nil {
+		return nil, err
+	}
+	envNames := []string{}
+	for _, p := range paths {
+		// Paths are <namespace>/<project>/<environment>/<name>.
+		rel, ok := strings.CutPrefix(p, projectPath+"/")
+		if envName, _, found := strings.Cut(rel, "/"); ok && found {
+			envNames = append(envNames, envName)
+		}
+	}
+	return lo.Uniq(envNames), nil
 }
 
 func (s *parameterStore) buildFilters(envID envsec.EnvID) []types.ParameterStringFilter {
@@ -202,8 +337,20 @@ func (s *parameterStore) buildFilters(envID envsec.EnvID) []types.ParameterStrin
 }
 
 func (s *parameterStore) getAll(ctx context.Context, envID envsec.EnvID, varNames []string) ([]envsec.EnvVar, error) {
+	params, err := s.getParameters(ctx, envID, varNames)
+	if err != nil {
+		return []envsec.EnvVar{}, err
+	}
+	return s.toEnvVars(ctx, envID, params)
+}
+
+func (s *parameterStore) getParameters(
+	ctx context.Context,
+	envID envsec.EnvID,
+	varNames []string,
+) ([]types.Parameter, error) {
 	// Start with empty results
-	results := []envsec.EnvVar{}
+	results := []types.Parameter{}
 	paths := lo.Map(varNames, func(name string, _ int) string {
 		return s.config.varPath(envID, name)
 	})
@@ -226,14 +373,59 @@ func (s *parameterStore) getAll(ctx context.Context, envID envsec.EnvID, varName
 		}
 
 		// Append results:
-		for _, p := range resp.Parameters {
-			results = append(results, envsec.EnvVar{
-				Name:  nameFromPath(aws.ToString(p.Name)),
-				Value: awsSSMParamStoreValueToString(p.Value),
-			})
-		}
+		results = append(results, resp.Parameters...)
+	}
+	return results, nil
+}
+
+// listParameters returns all the parameters of envID, including their
+// versions.
+func (s *parameterStore) listParameters(ctx context.Context, envID envsec.EnvID) ([]types.Parameter, error) {
+	if s.config.hasDefaultPaths() {
+		return s.parametersByPath(ctx, envID)
+	}
+	varNames, err := s.namesByTags(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getParameters(ctx, envID, varNames)
+}
+
+// toEnvVars returns the variables held by params, decoding the values that
+// are tagged as base64 encoded.
+func (s *parameterStore) toEnvVars(
+	ctx context.Context,
+	envID envsec.EnvID,
+	params []types.Parameter,
+) ([]envsec.EnvVar, error) {
+	results := []envsec.EnvVar{}
+	for _, p := range params {
+		results = append(results, envsec.EnvVar{
+			Name:  nameFromPath(aws.ToString(p.Name)),
+			Value: awsSSMParamStoreValueToString(p.Value),
+		})
 	}
 	sort(results)
+
+	// Reading tags takes another request, which is only needed if some value
+	// could be the encoding of a binary value.
+	if !lo.SomeBy(results, func(v envsec.EnvVar) bool { return isEncodedBinary(v.Value) }) {
+		return results, nil
+	}
+	encoded, err := s.encodedNames(ctx, envID)
+	if err != nil {
+		return results, err
+	}
+	for i, v := range results {
+		if !lo.Contains(encoded, v.Name) {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(v.Value)
+		if err != nil {
+			return results, errors.Wrapf(err, "invalid base64 value for %s", v.Name)
+		}
+		results[i].Value = string(value)
+	}
 	return results, nil
 }
 
@@ -294,12 +486,30 @@ func getFaultyParameter(message string) string {
 	return nameParts[0]
 }
 
+func encodingTag() types.Tag {
+	return types.Tag{
+		Key:   lo.ToPtr(encodingTagKey),
+		Value: lo.ToPtr(encodingTagBase64),
+	}
+}
+
+// isEncodedBinary reports whether text is the base64 encoding of a binary
+// value.
+func isEncodedBinary(text string) bool {
+	value, err := base64.StdEncoding.DecodeString(text)
+	return err == nil && envsec.IsBinary(string(value))
+}
+
 // AWS SSM Param store doesn't allow empty strings so we use a placeholder
-// instead
+// instead. It only stores text, so binary values are base64 encoded, and
+// their parameters tagged with encodingTagKey.
 func awsSSMParamStoreValue(s string) *string {
 	if s == "" {
 		return aws.String(emptyStringValuePlaceholder)
 	}
+	if envsec.IsBinary(s) {
+		return aws.String(base64.StdEncoding.EncodeToString([]byte(s)))
+	}
 	return aws.String(s)
 }
 
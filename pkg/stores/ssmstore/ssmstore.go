@@ -5,7 +5,9 @@ package ssmstore
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	cognitoTypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentity/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/hashicorp/go-multierror"
@@ -17,12 +19,23 @@ import (
 )
 
 type SSMStore struct {
-	store *parameterStore
+	// Region overrides the AWS region of the parameters, if set.
+	Region string
+	store  *parameterStore
 }
 
 // SSMStore implements interface Store (compile-time check)
 var _ envsec.Store = (*SSMStore)(nil)
 
+// SSMStore tracks versions of variables (compile-time check)
+var _ envsec.VersionedStore = (*SSMStore)(nil)
+
+// SSMStore limits the length of values (compile-time check)
+var _ envsec.ValueLimitedStore = (*SSMStore)(nil)
+
+// SSMStore lists the environments that have variables (compile-time check)
+var _ envsec.EnvironmentLister = (*SSMStore)(nil)
+
 func (s *SSMStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.Token, error) {
 	client, err := e.AuthClient()
 	if err != nil {
@@ -36,6 +49,9 @@ func (s *SSMStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if s.Region != "" {
+		ssmConfig.Region = s.Region
+	}
 	paramStore, err := newParameterStore(ctx, ssmConfig)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -44,6 +60,15 @@ func (s *SSMStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.
 	return tok, nil
 }
 
+// EffectiveRegion returns the AWS region of the parameters. Before InitForUser
+// it is only known if Region is set.
+func (s *SSMStore) EffectiveRegion() string {
+	if s.store == nil {
+		return s.Region
+	}
+	return s.store.client.Options().Region
+}
+
 func (s *SSMStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
 	if s.store.config.hasDefaultPaths() {
 		return s.store.listByPath(ctx, envID)
@@ -51,6 +76,31 @@ func (s *SSMStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVa
 	return s.store.listByTags(ctx, envID)
 }
 
+// ListEnvironments returns the environments of the project that have
+// parameters. Parameters with custom paths aren't listed.
+func (s *SSMStore) ListEnvironments(ctx context.Context, envID envsec.EnvID) ([]string, error) {
+	return s.store.environmentNames(ctx, envID)
+}
+
+// ListVersions returns the SSM parameter version of each variable.
+func (s *SSMStore) ListVersions(
+	ctx context.Context,
+	envID envsec.EnvID,
+) (map[string]envsec.Version, error) {
+	params, err := s.store.listParameters(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]envsec.Version{}
+	for _, p := range params {
+		versions[nameFromPath(aws.ToString(p.Name))] = envsec.Version{
+			ID:           strconv.FormatInt(p.Version, 10),
+			LastModified: aws.ToTime(p.LastModifiedDate),
+		}
+	}
+	return versions, nil
+}
+
 func (s *SSMStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
 	vars, err := s.GetAll(ctx, envID, []string{name})
 	if err != nil {
@@ -83,6 +133,11 @@ func (s *SSMStore) Set(
 	return s.store.newParameter(ctx, parameter, value)
 }
 
+// MaxValueLength returns the size limit of standard parameters.
+func (s *SSMStore) MaxValueLength() int {
+	return parameterValueMaxLength
+}
+
 func (s *SSMStore) SetAll(ctx context.Context, envID envsec.EnvID, values map[string]string) error {
 	// For now we implement by issuing multiple calls to Set()
 	// Make more efficient either by implementing a batch call to the underlying API, or